@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,20 +12,21 @@ import (
 
 // ActionResult 动作执行结果
 type ActionResult struct {
-	Success      bool   // 是否成功
-	ShouldFinish bool   // 是否完成任务
-	Message      string // 结果消息
+	Success          bool   // 是否成功
+	ShouldFinish     bool   // 是否完成任务
+	Message          string // 结果消息
+	SelectorNotFound bool   // 选择器未匹配到界面元素且没有备用坐标
 }
 
 // ActionHandler 动作处理器
 type ActionHandler struct {
-	deviceID             string
+	device               adb.Device
 	confirmationCallback func(message string) bool
 	takeoverCallback     func(message string)
 }
 
 // NewActionHandler 创建动作处理器
-func NewActionHandler(deviceID string, confirmationCallback func(string) bool, takeoverCallback func(string)) *ActionHandler {
+func NewActionHandler(device adb.Device, confirmationCallback func(string) bool, takeoverCallback func(string)) *ActionHandler {
 	if confirmationCallback == nil {
 		confirmationCallback = defaultConfirmationCallback
 	}
@@ -33,14 +35,14 @@ func NewActionHandler(deviceID string, confirmationCallback func(string) bool, t
 	}
 
 	return &ActionHandler{
-		deviceID:             deviceID,
+		device:               device,
 		confirmationCallback: confirmationCallback,
 		takeoverCallback:     takeoverCallback,
 	}
 }
 
 // Execute 执行动作
-func (h *ActionHandler) Execute(action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
+func (h *ActionHandler) Execute(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
 	metadata, _ := action["_metadata"].(string)
 
 	// 处理完成动作
@@ -65,23 +67,23 @@ func (h *ActionHandler) Execute(action map[string]interface{}, screenWidth, scre
 	actionName, _ := action["action"].(string)
 	switch actionName {
 	case "Launch":
-		return h.handleLaunch(action)
+		return h.handleLaunch(ctx, action)
 	case "Tap":
-		return h.handleTap(action, screenWidth, screenHeight)
+		return h.handleTap(ctx, action, screenWidth, screenHeight)
 	case "Type":
-		return h.handleType(action)
+		return h.handleType(ctx, action)
 	case "Swipe":
-		return h.handleSwipe(action, screenWidth, screenHeight)
+		return h.handleSwipe(ctx, action, screenWidth, screenHeight)
 	case "Back":
-		return h.handleBack()
+		return h.handleBack(ctx)
 	case "Home":
-		return h.handleHome()
+		return h.handleHome(ctx)
 	case "DoubleTap":
-		return h.handleDoubleTap(action, screenWidth, screenHeight)
+		return h.handleDoubleTap(ctx, action, screenWidth, screenHeight)
 	case "LongPress":
-		return h.handleLongPress(action, screenWidth, screenHeight)
+		return h.handleLongPress(ctx, action, screenWidth, screenHeight)
 	case "Wait":
-		return h.handleWait(action)
+		return h.handleWait(ctx, action)
 	case "Take_over":
 		return h.handleTakeover(action)
 	default:
@@ -94,7 +96,7 @@ func (h *ActionHandler) Execute(action map[string]interface{}, screenWidth, scre
 }
 
 // handleLaunch 处理启动应用
-func (h *ActionHandler) handleLaunch(action map[string]interface{}) (*ActionResult, error) {
+func (h *ActionHandler) handleLaunch(ctx context.Context, action map[string]interface{}) (*ActionResult, error) {
 	appName, _ := action["app"].(string)
 	if appName == "" {
 		return &ActionResult{
@@ -104,7 +106,7 @@ func (h *ActionHandler) handleLaunch(action map[string]interface{}) (*ActionResu
 		}, nil
 	}
 
-	success, err := adb.LaunchApp(appName, h.deviceID)
+	success, err := h.device.LaunchApp(ctx, appName)
 	if err != nil {
 		return &ActionResult{
 			Success:      false,
@@ -128,55 +130,90 @@ func (h *ActionHandler) handleLaunch(action map[string]interface{}) (*ActionResu
 }
 
 // handleTap 处理点击
-func (h *ActionHandler) handleTap(action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
-	element := action["element"]
-	if element == nil {
+func (h *ActionHandler) handleTap(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
+	x, y, failure := h.resolveTarget(ctx, action, screenWidth, screenHeight)
+	if failure != nil {
+		return failure, nil
+	}
+
+	if denied := h.confirmSensitive(action); denied != nil {
+		return denied, nil
+	}
+
+	if err := h.device.Tap(ctx, x, y); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
-			Message:      "No element coordinates",
+			Message:      err.Error(),
 		}, nil
 	}
 
-	// 检查敏感操作
-	if msg, ok := action["message"].(string); ok && msg != "" {
-		if !h.confirmationCallback(msg) {
-			return &ActionResult{
-				Success:      false,
-				ShouldFinish: true,
-				Message:      "User cancelled sensitive operation",
-			}, nil
+	return &ActionResult{
+		Success:      true,
+		ShouldFinish: false,
+	}, nil
+}
+
+// confirmSensitive 动作带 message 时视为敏感操作，经确认回调同意后才执行；被拒绝时返回结束任务的结果
+func (h *ActionHandler) confirmSensitive(action map[string]interface{}) *ActionResult {
+	msg, _ := action["message"].(string)
+	if msg == "" || h.confirmationCallback(msg) {
+		return nil
+	}
+	return &ActionResult{
+		Success:      false,
+		ShouldFinish: true,
+		Message:      "User cancelled sensitive operation",
+	}
+}
+
+// resolveTarget 解析点击类操作的目标像素坐标
+//
+// 有 selector 时先在界面层级中查找元素并使用其中心点，未匹配时使用 element 归一化坐标；
+// 两者都不可用时返回失败结果。
+func (h *ActionHandler) resolveTarget(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (int, int, *ActionResult) {
+	if sel, ok := ParseSelector(action["selector"]); ok {
+		if root, err := h.device.DumpUI(ctx); err == nil {
+			if node, found := FindNode(root, sel); found {
+				x, y := node.Bounds.Center()
+				return x, y, nil
+			}
+		}
+
+		if action["element"] == nil {
+			return 0, 0, &ActionResult{
+				Success:          false,
+				ShouldFinish:     false,
+				Message:          "No UI element matches selector",
+				SelectorNotFound: true,
+			}
 		}
 	}
 
-	// 转换坐标
-	coords, err := parseCoordinates(element)
-	if err != nil {
-		return &ActionResult{
+	element := action["element"]
+	if element == nil {
+		return 0, 0, &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
-			Message:      fmt.Sprintf("Failed to parse coordinates: %v", err),
-		}, nil
+			Message:      "No element coordinates",
+		}
 	}
-	x := int(float64(coords[0]) / 1000 * float64(screenWidth))
-	y := int(float64(coords[1]) / 1000 * float64(screenHeight))
 
-	if err := adb.Tap(x, y, h.deviceID); err != nil {
-		return &ActionResult{
+	// 转换坐标
+	coords, err := parseCoordinates(element)
+	if err != nil {
+		return 0, 0, &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
-			Message:      err.Error(),
-		}, nil
+			Message:      fmt.Sprintf("Failed to parse coordinates: %v", err),
+		}
 	}
-
-	return &ActionResult{
-		Success:      true,
-		ShouldFinish: false,
-	}, nil
+	x, y := toPixels(coords, screenWidth, screenHeight)
+	return x, y, nil
 }
 
 // handleType 处理输入文本
-func (h *ActionHandler) handleType(action map[string]interface{}) (*ActionResult, error) {
+func (h *ActionHandler) handleType(ctx context.Context, action map[string]interface{}) (*ActionResult, error) {
 	text, _ := action["text"].(string)
 	if text == "" {
 		return &ActionResult{
@@ -186,7 +223,7 @@ func (h *ActionHandler) handleType(action map[string]interface{}) (*ActionResult
 		}, nil
 	}
 
-	if err := adb.TypeText(text, h.deviceID); err != nil {
+	if err := h.device.TypeText(ctx, text); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -201,7 +238,7 @@ func (h *ActionHandler) handleType(action map[string]interface{}) (*ActionResult
 }
 
 // handleSwipe 处理滑动
-func (h *ActionHandler) handleSwipe(action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
+func (h *ActionHandler) handleSwipe(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
 	start := action["start"]
 	end := action["end"]
 	if start == nil || end == nil {
@@ -229,12 +266,10 @@ func (h *ActionHandler) handleSwipe(action map[string]interface{}, screenWidth,
 		}, nil
 	}
 
-	startX := int(float64(startCoords[0]) / 1000 * float64(screenWidth))
-	startY := int(float64(startCoords[1]) / 1000 * float64(screenHeight))
-	endX := int(float64(endCoords[0]) / 1000 * float64(screenWidth))
-	endY := int(float64(endCoords[1]) / 1000 * float64(screenHeight))
+	startX, startY := toPixels(startCoords, screenWidth, screenHeight)
+	endX, endY := toPixels(endCoords, screenWidth, screenHeight)
 
-	if err := adb.Swipe(startX, startY, endX, endY, 0, h.deviceID); err != nil {
+	if err := h.device.Swipe(ctx, startX, startY, endX, endY, 0); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -249,8 +284,8 @@ func (h *ActionHandler) handleSwipe(action map[string]interface{}, screenWidth,
 }
 
 // handleBack 处理返回
-func (h *ActionHandler) handleBack() (*ActionResult, error) {
-	if err := adb.Back(h.deviceID); err != nil {
+func (h *ActionHandler) handleBack(ctx context.Context) (*ActionResult, error) {
+	if err := h.device.Back(ctx); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -261,8 +296,8 @@ func (h *ActionHandler) handleBack() (*ActionResult, error) {
 }
 
 // handleHome 处理返回桌面
-func (h *ActionHandler) handleHome() (*ActionResult, error) {
-	if err := adb.Home(h.deviceID); err != nil {
+func (h *ActionHandler) handleHome(ctx context.Context) (*ActionResult, error) {
+	if err := h.device.Home(ctx); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -273,28 +308,17 @@ func (h *ActionHandler) handleHome() (*ActionResult, error) {
 }
 
 // handleDoubleTap 处理双击
-func (h *ActionHandler) handleDoubleTap(action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
-	element := action["element"]
-	if element == nil {
-		return &ActionResult{
-			Success:      false,
-			ShouldFinish: false,
-			Message:      "No element coordinates",
-		}, nil
+func (h *ActionHandler) handleDoubleTap(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
+	x, y, failure := h.resolveTarget(ctx, action, screenWidth, screenHeight)
+	if failure != nil {
+		return failure, nil
 	}
 
-	coords, err := parseCoordinates(element)
-	if err != nil {
-		return &ActionResult{
-			Success:      false,
-			ShouldFinish: false,
-			Message:      fmt.Sprintf("Failed to parse coordinates: %v", err),
-		}, nil
+	if denied := h.confirmSensitive(action); denied != nil {
+		return denied, nil
 	}
-	x := int(float64(coords[0]) / 1000 * float64(screenWidth))
-	y := int(float64(coords[1]) / 1000 * float64(screenHeight))
 
-	if err := adb.DoubleTap(x, y, h.deviceID); err != nil {
+	if err := h.device.DoubleTap(ctx, x, y); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -309,28 +333,17 @@ func (h *ActionHandler) handleDoubleTap(action map[string]interface{}, screenWid
 }
 
 // handleLongPress 处理长按
-func (h *ActionHandler) handleLongPress(action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
-	element := action["element"]
-	if element == nil {
-		return &ActionResult{
-			Success:      false,
-			ShouldFinish: false,
-			Message:      "No element coordinates",
-		}, nil
+func (h *ActionHandler) handleLongPress(ctx context.Context, action map[string]interface{}, screenWidth, screenHeight int) (*ActionResult, error) {
+	x, y, failure := h.resolveTarget(ctx, action, screenWidth, screenHeight)
+	if failure != nil {
+		return failure, nil
 	}
 
-	coords, err := parseCoordinates(element)
-	if err != nil {
-		return &ActionResult{
-			Success:      false,
-			ShouldFinish: false,
-			Message:      fmt.Sprintf("Failed to parse coordinates: %v", err),
-		}, nil
+	if denied := h.confirmSensitive(action); denied != nil {
+		return denied, nil
 	}
-	x := int(float64(coords[0]) / 1000 * float64(screenWidth))
-	y := int(float64(coords[1]) / 1000 * float64(screenHeight))
 
-	if err := adb.LongPress(x, y, 3000, h.deviceID); err != nil {
+	if err := h.device.LongPress(ctx, x, y, 3000); err != nil {
 		return &ActionResult{
 			Success:      false,
 			ShouldFinish: false,
@@ -345,7 +358,7 @@ func (h *ActionHandler) handleLongPress(action map[string]interface{}, screenWid
 }
 
 // handleWait 处理等待
-func (h *ActionHandler) handleWait(action map[string]interface{}) (*ActionResult, error) {
+func (h *ActionHandler) handleWait(ctx context.Context, action map[string]interface{}) (*ActionResult, error) {
 	durationStr, _ := action["duration"].(string)
 	if durationStr == "" {
 		durationStr = "1 seconds"
@@ -359,8 +372,18 @@ func (h *ActionHandler) handleWait(action map[string]interface{}) (*ActionResult
 		duration = 1.0
 	}
 
-	// 等待指定秒数
-	time.Sleep(time.Duration(duration * float64(time.Second)))
+	// 等待指定秒数，任务取消时提前结束
+	timer := time.NewTimer(time.Duration(duration * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return &ActionResult{
+			Success:      false,
+			ShouldFinish: true,
+			Message:      ctx.Err().Error(),
+		}, nil
+	case <-timer.C:
+	}
 
 	return &ActionResult{
 		Success:      true,
@@ -383,6 +406,21 @@ func (h *ActionHandler) handleTakeover(action map[string]interface{}) (*ActionRe
 	}, nil
 }
 
+// ScreenPoint 将动作中的 0-1000 归一化坐标换算为像素坐标，与执行动作时的换算一致
+func ScreenPoint(coords interface{}, screenWidth, screenHeight int) (int, int, error) {
+	c, err := parseCoordinates(coords)
+	if err != nil {
+		return 0, 0, err
+	}
+	x, y := toPixels(c, screenWidth, screenHeight)
+	return x, y, nil
+}
+
+// toPixels 0-1000 归一化坐标转像素坐标
+func toPixels(coords [2]float64, screenWidth, screenHeight int) (int, int) {
+	return int(coords[0] / 1000 * float64(screenWidth)), int(coords[1] / 1000 * float64(screenHeight))
+}
+
 // parseCoordinates 解析坐标
 func parseCoordinates(coords interface{}) ([2]float64, error) {
 	result := [2]float64{0, 0}
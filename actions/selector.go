@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"strconv"
+	"strings"
+
+	"go-phone-agent/adb"
+)
+
+// Selector 界面元素选择器，按 uiautomator 界面层级定位元素
+type Selector struct {
+	Text        string `json:"text,omitempty"`         // 文本，优先完全匹配，其次包含匹配
+	ResourceID  string `json:"resource_id,omitempty"`  // 资源 ID，可省略包名前缀
+	ContentDesc string `json:"content_desc,omitempty"` // 无障碍描述，优先完全匹配，其次包含匹配
+	Index       int    `json:"index"`                  // 单独使用时为界面元素列表中的 [序号]；与其他字段同时使用时为第几个匹配项；-1 表示未指定
+}
+
+// IsEmpty 判断选择器是否没有任何条件
+func (s *Selector) IsEmpty() bool {
+	return s.Text == "" && s.ResourceID == "" && s.ContentDesc == "" && s.Index < 0
+}
+
+// ParseSelector 从动作参数中解析选择器，支持 map 格式
+//
+// 字段名兼容 text、resource_id/resource-id/id、content_desc/content-desc/desc、index。
+func ParseSelector(v interface{}) (*Selector, bool) {
+	switch m := v.(type) {
+	case *Selector:
+		if m == nil || m.IsEmpty() {
+			return nil, false
+		}
+		return m, true
+	case Selector:
+		if m.IsEmpty() {
+			return nil, false
+		}
+		return &m, true
+	case map[string]interface{}:
+		sel := &Selector{
+			Text:        firstString(m, "text"),
+			ResourceID:  firstString(m, "resource_id", "resource-id", "id"),
+			ContentDesc: firstString(m, "content_desc", "content-desc", "desc"),
+			Index:       -1,
+		}
+		switch idx := m["index"].(type) {
+		case float64:
+			sel.Index = int(idx)
+		case int:
+			sel.Index = idx
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(idx)); err == nil {
+				sel.Index = n
+			}
+		}
+		if sel.IsEmpty() {
+			return nil, false
+		}
+		return sel, true
+	}
+	return nil, false
+}
+
+// firstString 返回第一个非空的字符串字段
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := m[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// FindNode 在界面层级中查找选择器匹配的节点
+func FindNode(root *adb.UINode, sel *Selector) (*adb.UINode, bool) {
+	if root == nil || sel == nil || sel.IsEmpty() {
+		return nil, false
+	}
+
+	// 只有序号：直接对应界面元素列表中的 [n]
+	if sel.Text == "" && sel.ResourceID == "" && sel.ContentDesc == "" {
+		nodes := adb.FlattenUI(root)
+		if sel.Index < len(nodes) {
+			return nodes[sel.Index], true
+		}
+		return nil, false
+	}
+
+	// 先完全匹配，没有结果再包含匹配
+	matches := collectMatches(root, sel, true)
+	if len(matches) == 0 {
+		matches = collectMatches(root, sel, false)
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	// 可点击的节点排在前面
+	ordered := make([]*adb.UINode, 0, len(matches))
+	for _, n := range matches {
+		if n.Clickable && n.Enabled {
+			ordered = append(ordered, n)
+		}
+	}
+	for _, n := range matches {
+		if !(n.Clickable && n.Enabled) {
+			ordered = append(ordered, n)
+		}
+	}
+
+	index := 0
+	if sel.Index > 0 {
+		index = sel.Index
+	}
+	if index >= len(ordered) {
+		return nil, false
+	}
+	return ordered[index], true
+}
+
+// collectMatches 深度优先收集匹配的节点
+func collectMatches(root *adb.UINode, sel *Selector, exact bool) []*adb.UINode {
+	var matches []*adb.UINode
+	var walk func(n *adb.UINode)
+	walk = func(n *adb.UINode) {
+		if !n.Bounds.Empty() && nodeMatches(n, sel, exact) {
+			matches = append(matches, n)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(root)
+	return matches
+}
+
+// nodeMatches 判断节点是否满足选择器的所有条件
+func nodeMatches(n *adb.UINode, sel *Selector, exact bool) bool {
+	if sel.Text != "" && !matchText(n.Text, sel.Text, exact) {
+		return false
+	}
+	if sel.ContentDesc != "" && !matchText(n.ContentDesc, sel.ContentDesc, exact) {
+		return false
+	}
+	if sel.ResourceID != "" {
+		if n.ResourceID != sel.ResourceID && !strings.HasSuffix(n.ResourceID, ":id/"+sel.ResourceID) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchText 文本匹配
+func matchText(value, want string, exact bool) bool {
+	if value == "" {
+		return false
+	}
+	if exact {
+		return strings.TrimSpace(value) == want
+	}
+	return strings.Contains(value, want)
+}
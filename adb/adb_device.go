@@ -0,0 +1,101 @@
+package adb
+
+import "context"
+
+// Device 设备操作接口，ActionHandler 和 PhoneAgent 只依赖该接口
+type Device interface {
+	// ID 返回设备标识
+	ID() string
+	// Tap 点击屏幕
+	Tap(ctx context.Context, x, y int) error
+	// DoubleTap 双击屏幕
+	DoubleTap(ctx context.Context, x, y int) error
+	// LongPress 长按屏幕
+	LongPress(ctx context.Context, x, y int, durationMS int) error
+	// Swipe 滑动屏幕
+	Swipe(ctx context.Context, startX, startY, endX, endY int, durationMS int) error
+	// Back 返回
+	Back(ctx context.Context) error
+	// Home 返回桌面
+	Home(ctx context.Context) error
+	// TypeText 输入文本
+	TypeText(ctx context.Context, text string) error
+	// LaunchApp 启动应用
+	LaunchApp(ctx context.Context, appName string) (bool, error)
+	// GetCurrentApp 获取当前应用
+	GetCurrentApp(ctx context.Context) string
+	// GetScreenshot 获取截图
+	GetScreenshot(ctx context.Context, timeout int) (*Screenshot, error)
+	// DumpUI 获取当前界面层级
+	DumpUI(ctx context.Context) (*UINode, error)
+}
+
+// ADBDevice 基于 ADB 命令行的设备实现
+type ADBDevice struct {
+	deviceID string
+}
+
+// NewADBDevice 创建 ADB 设备，deviceID 为空时使用默认设备
+func NewADBDevice(deviceID string) *ADBDevice {
+	return &ADBDevice{deviceID: deviceID}
+}
+
+// ID 返回设备标识
+func (d *ADBDevice) ID() string {
+	return d.deviceID
+}
+
+// Tap 点击屏幕
+func (d *ADBDevice) Tap(ctx context.Context, x, y int) error {
+	return Tap(ctx, x, y, d.deviceID)
+}
+
+// DoubleTap 双击屏幕
+func (d *ADBDevice) DoubleTap(ctx context.Context, x, y int) error {
+	return DoubleTap(ctx, x, y, d.deviceID)
+}
+
+// LongPress 长按屏幕
+func (d *ADBDevice) LongPress(ctx context.Context, x, y int, durationMS int) error {
+	return LongPress(ctx, x, y, durationMS, d.deviceID)
+}
+
+// Swipe 滑动屏幕
+func (d *ADBDevice) Swipe(ctx context.Context, startX, startY, endX, endY int, durationMS int) error {
+	return Swipe(ctx, startX, startY, endX, endY, durationMS, d.deviceID)
+}
+
+// Back 返回
+func (d *ADBDevice) Back(ctx context.Context) error {
+	return Back(ctx, d.deviceID)
+}
+
+// Home 返回桌面
+func (d *ADBDevice) Home(ctx context.Context) error {
+	return Home(ctx, d.deviceID)
+}
+
+// TypeText 输入文本
+func (d *ADBDevice) TypeText(ctx context.Context, text string) error {
+	return TypeText(ctx, text, d.deviceID)
+}
+
+// LaunchApp 启动应用
+func (d *ADBDevice) LaunchApp(ctx context.Context, appName string) (bool, error) {
+	return LaunchApp(ctx, appName, d.deviceID)
+}
+
+// GetCurrentApp 获取当前应用
+func (d *ADBDevice) GetCurrentApp(ctx context.Context) string {
+	return GetCurrentApp(ctx, d.deviceID)
+}
+
+// GetScreenshot 获取截图
+func (d *ADBDevice) GetScreenshot(ctx context.Context, timeout int) (*Screenshot, error) {
+	return GetScreenshot(ctx, d.deviceID, timeout)
+}
+
+// DumpUI 获取当前界面层级
+func (d *ADBDevice) DumpUI(ctx context.Context) (*UINode, error) {
+	return DumpUI(ctx, d.deviceID)
+}
@@ -1,8 +1,8 @@
 package adb
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
@@ -11,58 +11,46 @@ import (
 )
 
 // Tap 点击屏幕
-func Tap(x, y int, deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y))...)
-
-	if err := cmd.Run(); err != nil {
+func Tap(ctx context.Context, x, y int, deviceID string) error {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y)); err != nil {
 		return fmt.Errorf("tap failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond) // 默认延迟
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond) // 默认延迟
 }
 
 // DoubleTap 双击屏幕
-func DoubleTap(x, y int, deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-
+func DoubleTap(ctx context.Context, x, y int, deviceID string) error {
 	// 第一次点击
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y))...)
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y)); err != nil {
 		return fmt.Errorf("DoubleTap first failed: %w", err)
 	}
 
-	time.Sleep(100 * time.Millisecond) // 双击间隔
+	// 双击间隔
+	if err := sleepContext(ctx, 100*time.Millisecond); err != nil {
+		return err
+	}
 
 	// 第二次点击
-	cmd = exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y))...)
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "tap", strconv.Itoa(x), strconv.Itoa(y)); err != nil {
 		return fmt.Errorf("DoubleTap second failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond)
 }
 
 // LongPress 长按屏幕
-func LongPress(x, y int, durationMS int, deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "swipe",
-		strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(durationMS))...)
-
-	if err := cmd.Run(); err != nil {
+func LongPress(ctx context.Context, x, y int, durationMS int, deviceID string) error {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "swipe",
+		strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(x), strconv.Itoa(y), strconv.Itoa(durationMS)); err != nil {
 		return fmt.Errorf("long press failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond)
 }
 
 // Swipe 滑动屏幕
-func Swipe(startX, startY, endX, endY int, durationMS int, deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-
+func Swipe(ctx context.Context, startX, startY, endX, endY int, durationMS int, deviceID string) error {
 	// 自动计算滑动时长
 	if durationMS == 0 {
 		distSquared := float64((startX-endX)*(startX-endX) + (startY-endY)*(startY-endY))
@@ -74,75 +62,61 @@ func Swipe(startX, startY, endX, endY int, durationMS int, deviceID string) erro
 		}
 	}
 
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "swipe",
+	if _, err := runADB(ctx, deviceID, "shell", "input", "swipe",
 		strconv.Itoa(startX), strconv.Itoa(startY),
 		strconv.Itoa(endX), strconv.Itoa(endY),
-		strconv.Itoa(durationMS))...)
-
-	if err := cmd.Run(); err != nil {
+		strconv.Itoa(durationMS)); err != nil {
 		return fmt.Errorf("swipe failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond)
 }
 
 // Back 返回
-func Back(deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "keyevent", "4")...)
-
-	if err := cmd.Run(); err != nil {
+func Back(ctx context.Context, deviceID string) error {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "keyevent", "4"); err != nil {
 		return fmt.Errorf("back failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond)
 }
 
 // Home 返回桌面
-func Home(deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "input", "keyevent", "KEYCODE_HOME")...)
-
-	if err := cmd.Run(); err != nil {
+func Home(ctx context.Context, deviceID string) error {
+	if _, err := runADB(ctx, deviceID, "shell", "input", "keyevent", "KEYCODE_HOME"); err != nil {
 		return fmt.Errorf("home failed: %w", err)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-	return nil
+	return sleepContext(ctx, 500*time.Millisecond)
 }
 
 // LaunchApp 启动应用
-func LaunchApp(appName, deviceID string) (bool, error) {
+func LaunchApp(ctx context.Context, appName, deviceID string) (bool, error) {
 	packageName, ok := config.GetPackageName(appName)
 	if !ok {
 		return false, fmt.Errorf("app not found: %s", appName)
 	}
 
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "monkey", "-p", packageName,
-		"-c", "android.intent.category.LAUNCHER", "1")...)
-
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "shell", "monkey", "-p", packageName,
+		"-c", "android.intent.category.LAUNCHER", "1"); err != nil {
 		return false, fmt.Errorf("launch failed: %w", err)
 	}
 
-	time.Sleep(2000 * time.Millisecond) // 应用启动需要更长时间
+	// 应用启动需要更长时间
+	if err := sleepContext(ctx, 2000*time.Millisecond); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
 // GetCurrentApp 获取当前应用
-func GetCurrentApp(deviceID string) string {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "dumpsys", "window")...)
-
-	output, err := cmd.Output()
+func GetCurrentApp(ctx context.Context, deviceID string) string {
+	result, err := runADB(ctx, deviceID, "shell", "dumpsys", "window")
 	if err != nil {
 		return "System Home"
 	}
 
-	outputStr := string(output)
+	outputStr := string(result.Stdout)
 
 	// 查找当前焦点窗口
 	for appName, packageName := range config.AppPackages {
@@ -154,23 +128,14 @@ func GetCurrentApp(deviceID string) string {
 	return "System Home"
 }
 
-// buildADBPrefix 构建 ADB 命令前缀
-func buildADBPrefix(deviceID string) []string {
-	if deviceID != "" {
-		return []string{"adb", "-s", deviceID}
-	}
-	return []string{"adb"}
-}
-
 // ListDevices 列出已连接的设备
-func ListDevices() ([]string, error) {
-	cmd := exec.Command("adb", "devices")
-	output, err := cmd.Output()
+func ListDevices(ctx context.Context) ([]string, error) {
+	result, err := runADB(ctx, "", "devices")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list devices: %w", err)
 	}
 
-	lines := strings.Split(string(output), "\n")
+	lines := strings.Split(string(result.Stdout), "\n")
 	devices := []string{}
 
 	for _, line := range lines[1:] {
@@ -190,19 +155,26 @@ func ListDevices() ([]string, error) {
 	return devices, nil
 }
 
+// GetDeviceState 获取设备状态（device、offline、unauthorized 等），设备未连接时返回错误
+func GetDeviceState(ctx context.Context, deviceID string) (string, error) {
+	result, err := runADB(ctx, deviceID, "get-state")
+	if err != nil {
+		return "", fmt.Errorf("failed to get device state: %w, stderr: %s", err, strings.TrimSpace(string(result.Stderr)))
+	}
+	return strings.TrimSpace(string(result.Stdout)), nil
+}
+
 // ConnectDevice 连接远程设备
-func ConnectDevice(address string) error {
-	cmd := exec.Command("adb", "connect", address)
-	if err := cmd.Run(); err != nil {
+func ConnectDevice(ctx context.Context, address string) error {
+	if _, err := runADB(ctx, "", "connect", address); err != nil {
 		return fmt.Errorf("failed to connect device: %w", err)
 	}
 	return nil
 }
 
 // DisconnectDevice 断开设备连接
-func DisconnectDevice(address string) error {
-	cmd := exec.Command("adb", "disconnect", address)
-	if err := cmd.Run(); err != nil {
+func DisconnectDevice(ctx context.Context, address string) error {
+	if _, err := runADB(ctx, "", "disconnect", address); err != nil {
 		return fmt.Errorf("failed to disconnect device: %w", err)
 	}
 	return nil
@@ -0,0 +1,120 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// useRunner 在测试期间替换包内 runner，结束时恢复
+func useRunner(t *testing.T, r CommandRunner) {
+	t.Helper()
+	prev := SetRunner(r)
+	t.Cleanup(func() { SetRunner(prev) })
+}
+
+// loadFixtures 加载 testdata 中从真机采集的命令输出
+func loadFixtures(t *testing.T) *FixtureRunner {
+	t.Helper()
+	r, err := LoadFixtureRunner("testdata/pixel6.json")
+	if err != nil {
+		t.Fatalf("LoadFixtureRunner: %v", err)
+	}
+	return r
+}
+
+func TestListDevices(t *testing.T) {
+	r := loadFixtures(t)
+	useRunner(t, r)
+
+	devices, err := ListDevices(context.Background())
+	if err != nil {
+		t.Fatalf("ListDevices: %v", err)
+	}
+	// unauthorized、offline 的设备不可用
+	want := []string{"emulator-5554", "192.168.1.20:5555"}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("devices = %v, want %v", devices, want)
+	}
+	if !reflect.DeepEqual(r.Calls, []string{"devices"}) {
+		t.Errorf("calls = %v, want [devices]", r.Calls)
+	}
+}
+
+func TestListDevicesError(t *testing.T) {
+	useRunner(t, NewFixtureRunner(Fixture{Args: "devices", Stderr: "adb: server not running", ExitCode: 1}))
+
+	_, err := ListDevices(context.Background())
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("err = %v, want *ExitError with code 1", err)
+	}
+}
+
+func TestGetScreenSize(t *testing.T) {
+	r := loadFixtures(t)
+	useRunner(t, r)
+
+	width, height, err := GetScreenSize(context.Background(), "emulator-5554")
+	if err != nil {
+		t.Fatalf("GetScreenSize: %v", err)
+	}
+	// 以物理分辨率为准，忽略 Override size
+	if width != 1080 || height != 2400 {
+		t.Errorf("size = %dx%d, want 1080x2400", width, height)
+	}
+	if !reflect.DeepEqual(r.Calls, []string{"shell wm size"}) {
+		t.Errorf("calls = %v, want device args stripped", r.Calls)
+	}
+}
+
+func TestGetScreenSizeUnparsable(t *testing.T) {
+	useRunner(t, NewFixtureRunner(Fixture{Args: "shell wm size", Stdout: "error: no devices/emulators found\n"}))
+
+	if _, _, err := GetScreenSize(context.Background(), ""); err == nil {
+		t.Fatal("GetScreenSize succeeded on unparsable output")
+	}
+}
+
+func TestGetCurrentApp(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner *FixtureRunner
+		want   string
+	}{
+		{"focused app", loadFixtures(t), "微信"},
+		{"launcher", NewFixtureRunner(Fixture{
+			Args:   "shell dumpsys window",
+			Stdout: "  mCurrentFocus=Window{1d2c3b4 u0 com.google.android.apps.nexuslauncher/.NexusLauncherActivity}\n",
+		}), "System Home"},
+		{"command failed", NewFixtureRunner(Fixture{Args: "shell dumpsys window", ExitCode: 1}), "System Home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRunner(t, tt.runner)
+			if got := GetCurrentApp(context.Background(), "emulator-5554"); got != tt.want {
+				t.Errorf("GetCurrentApp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixtureRunnerSequence(t *testing.T) {
+	r := NewFixtureRunner(
+		Fixture{Args: "get-state", Stdout: "offline\n"},
+		Fixture{Args: "get-state", Stdout: "device\n"},
+	)
+	useRunner(t, r)
+
+	// 同一命令按顺序返回，用完后重复最后一条
+	for _, want := range []string{"offline", "device", "device"} {
+		state, err := GetDeviceState(context.Background(), "emulator-5554")
+		if err != nil {
+			t.Fatalf("GetDeviceState: %v", err)
+		}
+		if state != want {
+			t.Errorf("state = %q, want %q", state, want)
+		}
+	}
+}
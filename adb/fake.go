@@ -0,0 +1,142 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go-phone-agent/config"
+)
+
+// FakeDevice 内存中的假设备，记录所有操作，用于测试和离线回放
+type FakeDevice struct {
+	mu          sync.Mutex
+	deviceID    string
+	screenshots []*Screenshot // 按顺序返回的截图，最后一张会被重复使用
+	currentApp  string
+	UITree      *UINode  // DumpUI 返回的界面层级，为空时返回错误
+	Calls       []string // 已执行的操作记录
+}
+
+// NewFakeDevice 创建假设备
+func NewFakeDevice(deviceID string, screenshots ...*Screenshot) *FakeDevice {
+	return &FakeDevice{
+		deviceID:    deviceID,
+		screenshots: screenshots,
+		currentApp:  "System Home",
+	}
+}
+
+// PushScreenshot 追加一张待返回的截图
+func (d *FakeDevice) PushScreenshot(s *Screenshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.screenshots = append(d.screenshots, s)
+}
+
+// record 记录一次操作，ctx 已取消时不记录并返回错误
+func (d *FakeDevice) record(ctx context.Context, format string, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Calls = append(d.Calls, fmt.Sprintf(format, args...))
+	return nil
+}
+
+// ID 返回设备标识
+func (d *FakeDevice) ID() string {
+	return d.deviceID
+}
+
+// Tap 点击屏幕
+func (d *FakeDevice) Tap(ctx context.Context, x, y int) error {
+	return d.record(ctx, "Tap(%d,%d)", x, y)
+}
+
+// DoubleTap 双击屏幕
+func (d *FakeDevice) DoubleTap(ctx context.Context, x, y int) error {
+	return d.record(ctx, "DoubleTap(%d,%d)", x, y)
+}
+
+// LongPress 长按屏幕
+func (d *FakeDevice) LongPress(ctx context.Context, x, y int, durationMS int) error {
+	return d.record(ctx, "LongPress(%d,%d,%d)", x, y, durationMS)
+}
+
+// Swipe 滑动屏幕
+func (d *FakeDevice) Swipe(ctx context.Context, startX, startY, endX, endY int, durationMS int) error {
+	return d.record(ctx, "Swipe(%d,%d,%d,%d,%d)", startX, startY, endX, endY, durationMS)
+}
+
+// Back 返回
+func (d *FakeDevice) Back(ctx context.Context) error {
+	return d.record(ctx, "Back")
+}
+
+// Home 返回桌面
+func (d *FakeDevice) Home(ctx context.Context) error {
+	if err := d.record(ctx, "Home"); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.currentApp = "System Home"
+	d.mu.Unlock()
+	return nil
+}
+
+// TypeText 输入文本
+func (d *FakeDevice) TypeText(ctx context.Context, text string) error {
+	return d.record(ctx, "Type(%s)", text)
+}
+
+// LaunchApp 启动应用
+func (d *FakeDevice) LaunchApp(ctx context.Context, appName string) (bool, error) {
+	if _, ok := config.GetPackageName(appName); !ok {
+		return false, fmt.Errorf("app not found: %s", appName)
+	}
+	if err := d.record(ctx, "Launch(%s)", appName); err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	d.currentApp = appName
+	d.mu.Unlock()
+	return true, nil
+}
+
+// GetCurrentApp 获取当前应用
+func (d *FakeDevice) GetCurrentApp(ctx context.Context) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.currentApp
+}
+
+// GetScreenshot 依次返回预置的截图，没有预置截图时返回黑色占位图
+func (d *FakeDevice) GetScreenshot(ctx context.Context, timeout int) (*Screenshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.screenshots) == 0 {
+		return createFallbackScreenshot(false), nil
+	}
+
+	s := d.screenshots[0]
+	if len(d.screenshots) > 1 {
+		d.screenshots = d.screenshots[1:]
+	}
+	return s, nil
+}
+
+// DumpUI 返回预置的界面层级
+func (d *FakeDevice) DumpUI(ctx context.Context) (*UINode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.UITree == nil {
+		return nil, fmt.Errorf("no ui tree available")
+	}
+	return d.UITree, nil
+}
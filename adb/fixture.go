@@ -0,0 +1,190 @@
+package adb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fixture 一条录制的 adb 命令输出
+type Fixture struct {
+	Args         string `json:"args"`                    // 命令参数（不含 adb 和 -s <设备>），按前缀匹配
+	Stdout       string `json:"stdout,omitempty"`        // 文本标准输出
+	StdoutBase64 string `json:"stdout_base64,omitempty"` // 二进制标准输出（如截图），优先于 Stdout
+	Stderr       string `json:"stderr,omitempty"`        // 标准错误
+	ExitCode     int    `json:"exit_code"`               // 退出码
+}
+
+// stdout 返回标准输出字节
+func (f *Fixture) stdout() []byte {
+	if f.StdoutBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(f.StdoutBase64)
+		if err == nil {
+			return data
+		}
+	}
+	return []byte(f.Stdout)
+}
+
+// FixtureRunner 按预置脚本返回命令输出的假 runner，不依赖真实设备
+//
+// 匹配规则：取参数前缀最长的 fixture；同一参数的多条 fixture 按顺序依次返回，
+// 用完后重复返回最后一条。没有匹配时返回退出码 1。
+type FixtureRunner struct {
+	mu       sync.Mutex
+	fixtures []Fixture
+	used     []bool
+	Calls    []string // 已执行的命令（不含 -s <设备>）
+}
+
+// NewFixtureRunner 创建 fixture runner
+func NewFixtureRunner(fixtures ...Fixture) *FixtureRunner {
+	r := &FixtureRunner{}
+	for _, f := range fixtures {
+		r.Add(f)
+	}
+	return r
+}
+
+// LoadFixtureRunner 从 JSON 文件加载 fixture（格式为 Fixture 数组）
+func LoadFixtureRunner(path string) (*FixtureRunner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture file: %w", err)
+	}
+
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture file: %w", err)
+	}
+
+	return NewFixtureRunner(fixtures...), nil
+}
+
+// Add 追加一条 fixture
+func (r *FixtureRunner) Add(f Fixture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixtures = append(r.fixtures, f)
+	r.used = append(r.used, false)
+}
+
+// Run 返回匹配的 fixture 输出
+func (r *FixtureRunner) Run(ctx context.Context, args ...string) (*CommandResult, error) {
+	if err := ctx.Err(); err != nil {
+		return &CommandResult{ExitCode: -1}, err
+	}
+
+	cmdLine := strings.Join(stripDeviceArgs(args), " ")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Calls = append(r.Calls, cmdLine)
+
+	// 查找最长前缀
+	bestLen := -1
+	for _, f := range r.fixtures {
+		if strings.HasPrefix(cmdLine, f.Args) && len(f.Args) > bestLen {
+			bestLen = len(f.Args)
+		}
+	}
+	if bestLen < 0 {
+		return &CommandResult{ExitCode: 1}, &ExitError{Code: 1, Stderr: "no fixture for: " + cmdLine}
+	}
+
+	// 同一前缀按顺序消费，最后一条重复使用
+	match := -1
+	for i, f := range r.fixtures {
+		if len(f.Args) != bestLen || !strings.HasPrefix(cmdLine, f.Args) {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	r.used[match] = true
+
+	f := r.fixtures[match]
+	result := &CommandResult{
+		Stdout:   f.stdout(),
+		Stderr:   []byte(f.Stderr),
+		ExitCode: f.ExitCode,
+	}
+	if f.ExitCode != 0 {
+		return result, &ExitError{Code: f.ExitCode, Stderr: f.Stderr}
+	}
+	return result, nil
+}
+
+// RecordingRunner 包装真实 runner，记录每条命令的输出，用于从真机采集 fixture
+type RecordingRunner struct {
+	Runner   CommandRunner
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecordingRunner 创建录制 runner
+func NewRecordingRunner(inner CommandRunner) *RecordingRunner {
+	if inner == nil {
+		inner = ExecRunner{}
+	}
+	return &RecordingRunner{Runner: inner}
+}
+
+// Run 执行命令并记录输出
+func (r *RecordingRunner) Run(ctx context.Context, args ...string) (*CommandResult, error) {
+	result, err := r.Runner.Run(ctx, args...)
+	if result == nil {
+		return result, err
+	}
+
+	f := Fixture{
+		Args:     strings.Join(stripDeviceArgs(args), " "),
+		Stderr:   string(result.Stderr),
+		ExitCode: result.ExitCode,
+	}
+	if utf8.Valid(result.Stdout) {
+		f.Stdout = string(result.Stdout)
+	} else {
+		f.StdoutBase64 = base64.StdEncoding.EncodeToString(result.Stdout)
+	}
+
+	r.mu.Lock()
+	r.fixtures = append(r.fixtures, f)
+	r.mu.Unlock()
+
+	return result, err
+}
+
+// Fixtures 返回已录制的 fixture
+func (r *RecordingRunner) Fixtures() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fixture(nil), r.fixtures...)
+}
+
+// Save 将已录制的 fixture 写入 JSON 文件
+func (r *RecordingRunner) Save(path string) error {
+	data, err := json.MarshalIndent(r.Fixtures(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixtures: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write fixture file: %w", err)
+	}
+	return nil
+}
+
+// stripDeviceArgs 去掉开头的 -s <设备> 参数
+func stripDeviceArgs(args []string) []string {
+	if len(args) >= 2 && args[0] == "-s" {
+		return args[2:]
+	}
+	return args
+}
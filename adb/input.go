@@ -1,33 +1,30 @@
 package adb
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
 // TypeText 输入文本
-func TypeText(text, deviceID string) error {
+func TypeText(ctx context.Context, text, deviceID string) error {
 	// 切换到 ADB Keyboard
-	originalIME, err := detectAndSetADBKeyboard(deviceID)
+	originalIME, err := detectAndSetADBKeyboard(ctx, deviceID)
 	if err != nil {
 		return fmt.Errorf("failed to switch keyboard: %w", err)
 	}
-	defer restoreKeyboard(originalIME, deviceID)
+	// 即使任务被取消也要恢复输入法
+	defer restoreKeyboard(context.WithoutCancel(ctx), originalIME, deviceID)
 
 	// 清空文本框
-	if err := ClearText(deviceID); err != nil {
+	if err := ClearText(ctx, deviceID); err != nil {
 		return fmt.Errorf("failed to clear text: %w", err)
 	}
 
 	// 输入文本 - 使用 base64 编码
 	encodedText := base64.StdEncoding.EncodeToString([]byte(text))
-	cmdPrefix := buildADBPrefix(deviceID)
-	args := append(cmdPrefix[1:], "shell", "am", "broadcast", "-a", "ADB_INPUT_B64", "--es", "msg", encodedText)
-	cmd := exec.Command(cmdPrefix[0], args...)
-
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "shell", "am", "broadcast", "-a", "ADB_INPUT_B64", "--es", "msg", encodedText); err != nil {
 		return fmt.Errorf("type text failed: %w", err)
 	}
 
@@ -35,25 +32,19 @@ func TypeText(text, deviceID string) error {
 }
 
 // ClearText 清空输入框
-func ClearText(deviceID string) error {
-	cmdPrefix := buildADBPrefix(deviceID)
-	args := append(cmdPrefix[1:], "shell", "am", "broadcast", "-a", "ADB_CLEAR_TEXT")
-	cmd := exec.Command(cmdPrefix[0], args...)
-	return cmd.Run()
+func ClearText(ctx context.Context, deviceID string) error {
+	_, err := runADB(ctx, deviceID, "shell", "am", "broadcast", "-a", "ADB_CLEAR_TEXT")
+	return err
 }
 
 // detectAndSetADBKeyboard 检测并设置 ADB Keyboard
-func detectAndSetADBKeyboard(deviceID string) (string, error) {
-	cmdPrefix := buildADBPrefix(deviceID)
-
+func detectAndSetADBKeyboard(ctx context.Context, deviceID string) (string, error) {
 	// 获取当前输入法
-	args := append(cmdPrefix[1:], "shell", "settings", "get", "secure", "default_input_method")
-	cmd := exec.Command(cmdPrefix[0], args...)
-	output, err := cmd.Output()
+	result, err := runADB(ctx, deviceID, "shell", "settings", "get", "secure", "default_input_method")
 	if err != nil {
 		return "", err
 	}
-	currentIME := strings.TrimSpace(string(output))
+	currentIME := strings.TrimSpace(string(result.Stdout))
 
 	// 检查是否已经是 ADB Keyboard
 	if strings.Contains(currentIME, "com.android.adbkeyboard/.AdbIME") {
@@ -61,9 +52,7 @@ func detectAndSetADBKeyboard(deviceID string) (string, error) {
 	}
 
 	// 切换到 ADB Keyboard
-	args = append(cmdPrefix[1:], "shell", "ime", "set", "com.android.adbkeyboard/.AdbIME")
-	cmd = exec.Command(cmdPrefix[0], args...)
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "shell", "ime", "set", "com.android.adbkeyboard/.AdbIME"); err != nil {
 		return "", fmt.Errorf("failed to set ADB keyboard: %w", err)
 	}
 
@@ -71,28 +60,22 @@ func detectAndSetADBKeyboard(deviceID string) (string, error) {
 }
 
 // restoreKeyboard 恢复原始输入法
-func restoreKeyboard(originalIME, deviceID string) error {
+func restoreKeyboard(ctx context.Context, originalIME, deviceID string) error {
 	if originalIME == "" {
 		return nil
 	}
 
-	cmdPrefix := buildADBPrefix(deviceID)
-	args := append(cmdPrefix[1:], "shell", "ime", "set", originalIME)
-	cmd := exec.Command(cmdPrefix[0], args...)
-	return cmd.Run()
+	_, err := runADB(ctx, deviceID, "shell", "ime", "set", originalIME)
+	return err
 }
 
 // CheckADBKeyboard 检查 ADB Keyboard 是否已安装
-func CheckADBKeyboard(deviceID string) bool {
-	cmdPrefix := buildADBPrefix(deviceID)
-	args := append(cmdPrefix[1:], "shell", "ime", "list", "-s")
-	cmd := exec.Command(cmdPrefix[0], args...)
-
-	output, err := cmd.Output()
+func CheckADBKeyboard(ctx context.Context, deviceID string) bool {
+	result, err := runADB(ctx, deviceID, "shell", "ime", "list", "-s")
 	if err != nil {
 		return false
 	}
 
-	return strings.Contains(string(output), "com.android.adbkeyboard/.AdbIME")
+	return strings.Contains(string(result.Stdout), "com.android.adbkeyboard/.AdbIME")
 }
 
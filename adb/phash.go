@@ -0,0 +1,137 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+)
+
+// 截图对比参数
+const (
+	diffSampleWidth    = 108  // 像素对比前缩放到的宽度
+	diffPixelThreshold = 24   // 灰度差超过该值的像素视为变化（0-255）
+	statusBarRatio     = 0.04 // 忽略顶部状态栏（时间、电量会自行变化）
+)
+
+// ScreenDiff 两张截图的差异
+type ScreenDiff struct {
+	HashDistance int     // dHash 汉明距离（0-64）
+	PixelDiff    float64 // 变化像素占比（0-1）
+}
+
+// Changed 判断屏幕是否有可见变化：感知哈希不同，或有超过 minPixelDiff 比例的像素变化
+func (d ScreenDiff) Changed(minPixelDiff float64) bool {
+	return d.HashDistance > 0 || d.PixelDiff > minPixelDiff
+}
+
+// DHash 计算截图的 64 位差值哈希（忽略状态栏），相似画面的哈希汉明距离很小
+func DHash(s *Screenshot) (uint64, error) {
+	img, err := decodeForDiff(s)
+	if err != nil {
+		return 0, err
+	}
+	return dHashImage(img), nil
+}
+
+// dHashImage 计算图片的差值哈希：缩放到 9x8 灰度，比较相邻像素亮度
+func dHashImage(img image.Image) uint64 {
+	small := imaging.Resize(imaging.Grayscale(img), 9, 8, imaging.Box)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.Pix[small.PixOffset(x, y)] > small.Pix[small.PixOffset(x+1, y)] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance 两个哈希不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// CompareScreenshots 对比两张截图，返回感知哈希距离和像素差异比例
+func CompareScreenshots(before, after *Screenshot) (ScreenDiff, error) {
+	a, err := Fingerprint(before)
+	if err != nil {
+		return ScreenDiff{}, fmt.Errorf("before: %w", err)
+	}
+	b, err := Fingerprint(after)
+	if err != nil {
+		return ScreenDiff{}, fmt.Errorf("after: %w", err)
+	}
+	return a.Diff(b), nil
+}
+
+// ScreenFingerprint 截图的感知指纹（忽略状态栏），保存后可以不保留原图与新截图对比
+type ScreenFingerprint struct {
+	Hash   uint64 `json:"hash"`   // dHash
+	Height int    `json:"height"` // 缩略图高度，宽度为 diffSampleWidth
+	Pixels []byte `json:"pixels"` // 缩略图灰度像素
+}
+
+// Fingerprint 计算截图的感知指纹
+func Fingerprint(s *Screenshot) (*ScreenFingerprint, error) {
+	img, err := decodeForDiff(s)
+	if err != nil {
+		return nil, err
+	}
+
+	height := diffSampleWidth * img.Bounds().Dy() / max(img.Bounds().Dx(), 1)
+	thumb := imaging.Grayscale(imaging.Resize(img, diffSampleWidth, height, imaging.Box))
+	pixels := make([]byte, 0, diffSampleWidth*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < diffSampleWidth; x++ {
+			pixels = append(pixels, thumb.Pix[thumb.PixOffset(x, y)])
+		}
+	}
+	return &ScreenFingerprint{Hash: dHashImage(img), Height: height, Pixels: pixels}, nil
+}
+
+// Diff 对比两个指纹；宽高比不同（如屏幕旋转）时视为完全不同
+func (f *ScreenFingerprint) Diff(other *ScreenFingerprint) ScreenDiff {
+	diff := ScreenDiff{HashDistance: HammingDistance(f.Hash, other.Hash)}
+	if f.Height != other.Height || len(f.Pixels) != len(other.Pixels) {
+		diff.PixelDiff = 1
+		return diff
+	}
+
+	changed := 0
+	for i := range f.Pixels {
+		pa, pb := int(f.Pixels[i]), int(other.Pixels[i])
+		if pa-pb > diffPixelThreshold || pb-pa > diffPixelThreshold {
+			changed++
+		}
+	}
+	if len(f.Pixels) > 0 {
+		diff.PixelDiff = float64(changed) / float64(len(f.Pixels))
+	}
+	return diff
+}
+
+// decodeForDiff 解码截图并裁掉顶部状态栏
+func decodeForDiff(s *Screenshot) (image.Image, error) {
+	if s == nil || s.Base64Data == "" {
+		return nil, fmt.Errorf("empty screenshot")
+	}
+	data, err := base64.StdEncoding.DecodeString(s.Base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	top := int(float64(bounds.Dy()) * statusBarRatio)
+	return imaging.Crop(img, image.Rect(bounds.Min.X, bounds.Min.Y+top, bounds.Max.X, bounds.Max.Y)), nil
+}
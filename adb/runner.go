@@ -0,0 +1,138 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandResult adb 命令的执行结果
+type CommandResult struct {
+	Stdout   []byte // 标准输出
+	Stderr   []byte // 标准错误
+	ExitCode int    // 退出码
+}
+
+// CommandRunner 执行 adb 命令的接口，args 不包含 "adb" 本身
+type CommandRunner interface {
+	Run(ctx context.Context, args ...string) (*CommandResult, error)
+}
+
+// ExitError 命令以非零退出码结束
+type ExitError struct {
+	Code   int
+	Stderr string
+}
+
+// Error 实现 error 接口
+func (e *ExitError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("exit status %d: %s", e.Code, strings.TrimSpace(e.Stderr))
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExecRunner 通过本机 adb 可执行文件运行命令
+type ExecRunner struct {
+	Path string // adb 可执行文件路径，为空时使用 PATH 中的 adb
+}
+
+// Run 执行 adb 命令，非零退出码返回 *ExitError，结果中仍包含已捕获的输出
+func (r ExecRunner) Run(ctx context.Context, args ...string) (*CommandResult, error) {
+	path := r.Path
+	if path == "" {
+		path = "adb"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := &CommandResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+			return result, &ExitError{Code: result.ExitCode, Stderr: stderr.String()}
+		}
+		result.ExitCode = -1
+		return result, err
+	}
+	return result, nil
+}
+
+var (
+	runnerMu       sync.RWMutex
+	runner         CommandRunner = ExecRunner{}
+	commandTimeout               = 30 * time.Second // 单条命令默认超时
+)
+
+// SetRunner 替换包内所有 adb 调用使用的 runner，返回之前的 runner 以便恢复
+func SetRunner(r CommandRunner) CommandRunner {
+	runnerMu.Lock()
+	defer runnerMu.Unlock()
+	prev := runner
+	runner = r
+	return prev
+}
+
+// currentRunner 获取当前 runner
+func currentRunner() CommandRunner {
+	runnerMu.RLock()
+	defer runnerMu.RUnlock()
+	return runner
+}
+
+// SetCommandTimeout 设置单条 adb 命令的默认超时，调用方 ctx 没有截止时间时生效，<=0 表示不限制
+func SetCommandTimeout(timeout time.Duration) {
+	runnerMu.Lock()
+	defer runnerMu.Unlock()
+	commandTimeout = timeout
+}
+
+// runADB 在指定设备上执行 adb 命令，ctx 取消或超时时终止命令
+func runADB(ctx context.Context, deviceID string, args ...string) (*CommandResult, error) {
+	runnerMu.RLock()
+	timeout := commandTimeout
+	runnerMu.RUnlock()
+
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := currentRunner().Run(ctx, append(deviceArgs(deviceID), args...)...)
+	if result == nil {
+		result = &CommandResult{ExitCode: -1}
+	}
+	return result, err
+}
+
+// deviceArgs 构建选择设备的参数
+func deviceArgs(deviceID string) []string {
+	if deviceID != "" {
+		return []string{"-s", deviceID}
+	}
+	return nil
+}
+
+// sleepContext 等待指定时长，ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -2,11 +2,12 @@ package adb
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
+	_ "image/png"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -19,63 +20,102 @@ type Screenshot struct {
 	Width        int    // 屏幕宽度
 	Height       int    // 屏幕高度
 	IsSensitive  bool   // 是否为敏感页面
+	MimeType     string // 图片 MIME 类型（image/png 或 image/jpeg）
 }
 
-// GetScreenshot 获取设备截图
-func GetScreenshot(deviceID string, timeout int) (*Screenshot, error) {
-	// 构建命令前缀
-	cmdPrefix := buildADBPrefix(deviceID)
+// GetScreenshot 获取设备截图，timeout 为超时秒数（<=0 表示不超时）
+//
+// 优先通过 adb exec-out 直接读取 screencap 的 PNG 输出，不落盘也不重新编码；
+// exec-out 不可用时回退到 screencap 到 /sdcard 再 pull 的方式。
+func GetScreenshot(ctx context.Context, deviceID string, timeout int) (*Screenshot, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
 
-	// 执行截图到设备
-	tempPath := "/sdcard/tmp.png"
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "screencap", "-p", tempPath)...)
+	result, err := runADB(ctx, deviceID, "exec-out", "screencap", "-p")
+	if isSensitiveScreen(result.Stderr) {
+		return createFallbackScreenshot(true), nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("screenshot aborted: %w", ctx.Err())
+	}
+	if err == nil && len(result.Stdout) > 0 {
+		if screenshot, decodeErr := decodeScreenshot(result.Stdout); decodeErr == nil {
+			return screenshot, nil
+		}
+	}
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	// exec-out 失败或输出不是有效 PNG（旧版 adb），回退到 pull 方式
+	return getScreenshotByPull(ctx, deviceID)
+}
 
-	if err := cmd.Run(); err != nil {
+// getScreenshotByPull 截图到设备存储后再拉取到本地
+func getScreenshotByPull(ctx context.Context, deviceID string) (*Screenshot, error) {
+	// 执行截图到设备
+	tempPath := "/sdcard/tmp.png"
+	result, err := runADB(ctx, deviceID, "shell", "screencap", "-p", tempPath)
+	if err != nil {
 		// 检查是否是敏感页面
-		if strings.Contains(stderr.String(), "Status: -1") || strings.Contains(stderr.String(), "Failed") {
+		if isSensitiveScreen(result.Stderr) {
 			return createFallbackScreenshot(true), nil
 		}
-		return nil, fmt.Errorf("screenshot failed: %w, stderr: %s", err, stderr.String())
+		return nil, fmt.Errorf("screenshot failed: %w, stderr: %s", err, string(result.Stderr))
 	}
 
 	// 拉取截图到本地
-	localTempPath := fmt.Sprintf("%s%s%d.png", os.TempDir(), string(os.PathSeparator), time.Now().UnixNano())
-	cmd = exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "pull", tempPath, localTempPath)...)
+	// 多台设备同时截图时本地文件名不能冲突
+	localFile, err := os.CreateTemp("", "phone-agent-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	localTempPath := localFile.Name()
+	localFile.Close()
+	defer os.Remove(localTempPath)
 
-	if err := cmd.Run(); err != nil {
+	if _, err := runADB(ctx, deviceID, "pull", tempPath, localTempPath); err != nil {
 		return createFallbackScreenshot(false), nil
 	}
 
-	// 读取图片
-	img, err := imaging.Open(localTempPath)
+	data, err := os.ReadFile(localTempPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %w", err)
+		return nil, fmt.Errorf("failed to read image: %w", err)
 	}
-	defer os.Remove(localTempPath)
 
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
+	return decodeScreenshot(data)
+}
 
-	// 转换为 base64
-	var buf bytes.Buffer
-	if err := imaging.Encode(&buf, img, imaging.PNG); err != nil {
-		return nil, fmt.Errorf("failed to encode image: %w", err)
-	}
+// DecodeScreenshot 从 PNG 数据构建截图（用于加载记录的截图）
+func DecodeScreenshot(data []byte) (*Screenshot, error) {
+	return decodeScreenshot(data)
+}
 
-	base64Data := base64.StdEncoding.EncodeToString(buf.Bytes())
+// decodeScreenshot 只解析图片头获取尺寸，原始字节直接转为 base64
+func decodeScreenshot(data []byte) (*Screenshot, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if format != "png" {
+		return nil, fmt.Errorf("unexpected image format: %s", format)
+	}
 
 	return &Screenshot{
-		Base64Data:  base64Data,
-		Width:       width,
-		Height:      height,
+		Base64Data:  base64.StdEncoding.EncodeToString(data),
+		Width:       cfg.Width,
+		Height:      cfg.Height,
 		IsSensitive: false,
+		MimeType:    "image/png",
 	}, nil
 }
 
+// isSensitiveScreen 根据 screencap 的错误输出判断是否为禁止截图的敏感页面
+func isSensitiveScreen(stderr []byte) bool {
+	s := string(stderr)
+	return strings.Contains(s, "Status: -1") || strings.Contains(s, "Failed")
+}
+
 // createFallbackScreenshot 创建黑色占位图
 func createFallbackScreenshot(isSensitive bool) *Screenshot {
 	width, height := 1080, 2400
@@ -91,21 +131,19 @@ func createFallbackScreenshot(isSensitive bool) *Screenshot {
 		Width:       width,
 		Height:      height,
 		IsSensitive:  isSensitive,
+		MimeType:     "image/png",
 	}
 }
 
 // GetScreenSize 获取屏幕分辨率
-func GetScreenSize(deviceID string) (int, int, error) {
-	cmdPrefix := buildADBPrefix(deviceID)
-	cmd := exec.Command(cmdPrefix[0], append(cmdPrefix[1:], "shell", "wm", "size")...)
-
-	output, err := cmd.Output()
+func GetScreenSize(ctx context.Context, deviceID string) (int, int, error) {
+	result, err := runADB(ctx, deviceID, "shell", "wm", "size")
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get screen size: %w", err)
 	}
 
 	// 解析输出: Physical size: 1080x2400
-	strOutput := string(output)
+	strOutput := string(result.Stdout)
 	var width, height int
 	_, err = fmt.Sscanf(strOutput, "Physical size: %dx%d", &width, &height)
 	if err != nil {
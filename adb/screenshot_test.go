@@ -0,0 +1,84 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// pngFixture 生成指定尺寸的 PNG 截图输出
+func pngFixture(t *testing.T, width, height int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.New(width, height, color.White), imaging.PNG); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestGetScreenshot(t *testing.T) {
+	r := NewFixtureRunner(Fixture{Args: "exec-out screencap -p", StdoutBase64: pngFixture(t, 1080, 2400)})
+	useRunner(t, r)
+
+	screenshot, err := GetScreenshot(context.Background(), "emulator-5554", 10)
+	if err != nil {
+		t.Fatalf("GetScreenshot: %v", err)
+	}
+	if screenshot.IsSensitive {
+		t.Error("screenshot marked sensitive")
+	}
+	if screenshot.Width != 1080 || screenshot.Height != 2400 {
+		t.Errorf("size = %dx%d, want 1080x2400", screenshot.Width, screenshot.Height)
+	}
+	if len(r.Calls) != 1 {
+		t.Errorf("calls = %v, want a single exec-out", r.Calls)
+	}
+}
+
+func TestGetScreenshotSensitive(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures []Fixture
+	}{
+		{"exec-out", []Fixture{
+			{Args: "exec-out screencap -p", Stderr: "Status: -1", ExitCode: 1},
+		}},
+		{"pull fallback", []Fixture{
+			{Args: "exec-out screencap -p", Stdout: "not a png"},
+			{Args: "shell screencap -p", Stderr: "Failed to take screenshot (secure window)", ExitCode: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRunner(t, NewFixtureRunner(tt.fixtures...))
+
+			screenshot, err := GetScreenshot(context.Background(), "emulator-5554", 10)
+			if err != nil {
+				t.Fatalf("GetScreenshot: %v", err)
+			}
+			if !screenshot.IsSensitive {
+				t.Error("secure screen not detected")
+			}
+			if screenshot.Width != 1080 || screenshot.Height != 2400 {
+				t.Errorf("placeholder size = %dx%d, want 1080x2400", screenshot.Width, screenshot.Height)
+			}
+		})
+	}
+}
+
+func TestGetScreenshotFailure(t *testing.T) {
+	useRunner(t, NewFixtureRunner(
+		Fixture{Args: "exec-out screencap -p", ExitCode: 1},
+		Fixture{Args: "shell screencap -p", Stderr: "error: device offline", ExitCode: 1},
+	))
+
+	_, err := GetScreenshot(context.Background(), "emulator-5554", 10)
+	if err == nil || !strings.Contains(err.Error(), "device offline") {
+		t.Fatalf("err = %v, want screenshot failure with stderr", err)
+	}
+}
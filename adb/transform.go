@@ -0,0 +1,76 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/disintegration/imaging"
+)
+
+// ScreenshotOptions 截图发送给视觉模型前的处理选项
+type ScreenshotOptions struct {
+	MaxLongEdge int  // 长边最大像素，0 表示不缩放
+	JPEGQuality int  // JPEG 质量（1-100），0 表示保持 PNG
+	Grayscale   bool // 是否转为灰度图
+}
+
+// needsTransform 判断截图是否需要重新编码
+func (o ScreenshotOptions) needsTransform(s *Screenshot) bool {
+	longEdge := max(s.Width, s.Height)
+	return (o.MaxLongEdge > 0 && longEdge > o.MaxLongEdge) || o.JPEGQuality > 0 || o.Grayscale
+}
+
+// ProcessScreenshot 按选项缩放、转灰度并重新编码截图
+//
+// 返回的截图 Width/Height 仍是设备原始分辨率，模型返回的是 0-1000 归一化坐标，
+// 缩放不影响点击位置的换算。不需要处理时原样返回，不做任何编解码。
+func ProcessScreenshot(s *Screenshot, opts ScreenshotOptions) (*Screenshot, error) {
+	if s == nil || !opts.needsTransform(s) {
+		return s, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(s.Base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot data: %w", err)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// 按长边等比缩放
+	bounds := img.Bounds()
+	if opts.MaxLongEdge > 0 && max(bounds.Dx(), bounds.Dy()) > opts.MaxLongEdge {
+		if bounds.Dx() >= bounds.Dy() {
+			img = imaging.Resize(img, opts.MaxLongEdge, 0, imaging.Linear)
+		} else {
+			img = imaging.Resize(img, 0, opts.MaxLongEdge, imaging.Linear)
+		}
+	}
+
+	if opts.Grayscale {
+		img = imaging.Grayscale(img)
+	}
+
+	var buf bytes.Buffer
+	mimeType := "image/png"
+	if opts.JPEGQuality > 0 {
+		mimeType = "image/jpeg"
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(opts.JPEGQuality))
+	} else {
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return &Screenshot{
+		Base64Data:  base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Width:       s.Width,
+		Height:      s.Height,
+		IsSensitive: s.IsSensitive,
+		MimeType:    mimeType,
+	}, nil
+}
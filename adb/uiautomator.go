@@ -0,0 +1,226 @@
+package adb
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rect 元素边界（像素）
+type Rect struct {
+	Left   int
+	Top    int
+	Right  int
+	Bottom int
+}
+
+// Center 返回边界中心点
+func (r Rect) Center() (int, int) {
+	return (r.Left + r.Right) / 2, (r.Top + r.Bottom) / 2
+}
+
+// Empty 判断边界是否为空
+func (r Rect) Empty() bool {
+	return r.Right <= r.Left || r.Bottom <= r.Top
+}
+
+// UINode uiautomator 界面层级中的一个节点
+type UINode struct {
+	Index       int       // 在父节点中的序号
+	Text        string    // 文本
+	ResourceID  string    // 资源 ID，如 com.tencent.mm:id/title
+	Class       string    // 控件类名
+	Package     string    // 所属包名
+	ContentDesc string    // 无障碍描述
+	Clickable   bool      // 是否可点击
+	Enabled     bool      // 是否可用
+	Bounds      Rect      // 边界
+	Children    []*UINode // 子节点
+}
+
+// xmlNode uiautomator dump 输出的 XML 节点
+type xmlNode struct {
+	Index       string    `xml:"index,attr"`
+	Text        string    `xml:"text,attr"`
+	ResourceID  string    `xml:"resource-id,attr"`
+	Class       string    `xml:"class,attr"`
+	Package     string    `xml:"package,attr"`
+	ContentDesc string    `xml:"content-desc,attr"`
+	Clickable   string    `xml:"clickable,attr"`
+	Enabled     string    `xml:"enabled,attr"`
+	Bounds      string    `xml:"bounds,attr"`
+	Nodes       []xmlNode `xml:"node"`
+}
+
+// xmlHierarchy XML 根节点
+type xmlHierarchy struct {
+	Nodes []xmlNode `xml:"node"`
+}
+
+// DumpUI 通过 uiautomator dump 获取当前界面层级
+func DumpUI(ctx context.Context, deviceID string) (*UINode, error) {
+	dumpPath := "/sdcard/window_dump.xml"
+	if result, err := runADB(ctx, deviceID, "shell", "uiautomator", "dump", dumpPath); err != nil {
+		return nil, fmt.Errorf("uiautomator dump failed: %w, stderr: %s", err, string(result.Stderr))
+	}
+
+	result, err := runADB(ctx, deviceID, "exec-out", "cat", dumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ui dump: %w", err)
+	}
+
+	return ParseUIHierarchy(result.Stdout)
+}
+
+// ParseUIHierarchy 解析 uiautomator dump 的 XML，返回虚拟根节点
+func ParseUIHierarchy(data []byte) (*UINode, error) {
+	// dump 到 /dev/tty 时 XML 后面会跟提示文字，只保留 XML 部分
+	if end := strings.LastIndex(string(data), "</hierarchy>"); end >= 0 {
+		data = data[:end+len("</hierarchy>")]
+	}
+
+	var hierarchy xmlHierarchy
+	if err := xml.Unmarshal(data, &hierarchy); err != nil {
+		return nil, fmt.Errorf("failed to parse ui hierarchy: %w", err)
+	}
+	if len(hierarchy.Nodes) == 0 {
+		return nil, fmt.Errorf("empty ui hierarchy")
+	}
+
+	root := &UINode{Enabled: true}
+	for _, n := range hierarchy.Nodes {
+		child := convertXMLNode(n)
+		root.Children = append(root.Children, child)
+		root.Bounds = unionRect(root.Bounds, child.Bounds)
+	}
+
+	return root, nil
+}
+
+// convertXMLNode 将 XML 节点转换为 UINode
+func convertXMLNode(n xmlNode) *UINode {
+	index, _ := strconv.Atoi(n.Index)
+	node := &UINode{
+		Index:       index,
+		Text:        n.Text,
+		ResourceID:  n.ResourceID,
+		Class:       n.Class,
+		Package:     n.Package,
+		ContentDesc: n.ContentDesc,
+		Clickable:   n.Clickable == "true",
+		Enabled:     n.Enabled != "false",
+		Bounds:      parseBounds(n.Bounds),
+	}
+	for _, c := range n.Nodes {
+		node.Children = append(node.Children, convertXMLNode(c))
+	}
+	return node
+}
+
+// parseBounds 解析 "[0,0][1080,2400]" 格式的边界
+func parseBounds(s string) Rect {
+	var r Rect
+	fmt.Sscanf(s, "[%d,%d][%d,%d]", &r.Left, &r.Top, &r.Right, &r.Bottom)
+	return r
+}
+
+// unionRect 合并两个边界
+func unionRect(a, b Rect) Rect {
+	if a.Empty() {
+		return b
+	}
+	if b.Empty() {
+		return a
+	}
+	return Rect{
+		Left:   min(a.Left, b.Left),
+		Top:    min(a.Top, b.Top),
+		Right:  max(a.Right, b.Right),
+		Bottom: max(a.Bottom, b.Bottom),
+	}
+}
+
+// maxRenderedNodes 文本渲染最多包含的节点数，避免决策模型上下文过长
+const maxRenderedNodes = 200
+
+// FlattenUI 按深度优先顺序返回有意义的节点（有文字、描述或可点击）
+//
+// 返回的序号与 RenderUITree 输出中的 [n] 一一对应。
+func FlattenUI(root *UINode) []*UINode {
+	var nodes []*UINode
+	var walk func(n *UINode)
+	walk = func(n *UINode) {
+		if len(nodes) >= maxRenderedNodes {
+			return
+		}
+		if !n.Bounds.Empty() && (n.Text != "" || n.ContentDesc != "" || n.Clickable) {
+			nodes = append(nodes, n)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	if root != nil {
+		walk(root)
+	}
+	return nodes
+}
+
+// RenderUITree 将界面层级渲染为紧凑文本，坐标归一化为 0-1000
+//
+// 每行格式：[序号] 类名 "文本" desc="描述" id=资源ID clickable center=[x,y]
+func RenderUITree(root *UINode, screenWidth, screenHeight int) string {
+	if screenWidth <= 0 || screenHeight <= 0 {
+		if root == nil {
+			return ""
+		}
+		screenWidth, screenHeight = root.Bounds.Right, root.Bounds.Bottom
+	}
+	if screenWidth <= 0 || screenHeight <= 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, n := range FlattenUI(root) {
+		fmt.Fprintf(&sb, "[%d] %s", i, shortClassName(n.Class))
+		if n.Text != "" {
+			fmt.Fprintf(&sb, " %q", n.Text)
+		}
+		if n.ContentDesc != "" {
+			fmt.Fprintf(&sb, " desc=%q", n.ContentDesc)
+		}
+		if id := shortResourceID(n.ResourceID); id != "" {
+			fmt.Fprintf(&sb, " id=%s", id)
+		}
+		if n.Clickable {
+			sb.WriteString(" clickable")
+		}
+		if !n.Enabled {
+			sb.WriteString(" disabled")
+		}
+		x, y := n.Bounds.Center()
+		fmt.Fprintf(&sb, " center=[%d,%d]\n", x*1000/screenWidth, y*1000/screenHeight)
+	}
+	return sb.String()
+}
+
+// shortClassName 去掉类名的包前缀
+func shortClassName(class string) string {
+	if i := strings.LastIndex(class, "."); i >= 0 {
+		return class[i+1:]
+	}
+	if class == "" {
+		return "View"
+	}
+	return class
+}
+
+// shortResourceID 去掉资源 ID 的包名前缀
+func shortResourceID(id string) string {
+	if i := strings.Index(id, ":id/"); i >= 0 {
+		return id[i+len(":id/"):]
+	}
+	return id
+}
@@ -1,30 +1,58 @@
 package agent
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"go-phone-agent/actions"
 	"go-phone-agent/adb"
 	"go-phone-agent/model"
+	"go-phone-agent/trace"
 )
 
 // PhoneAgent 手机自动化 Agent
+//
+// 每台设备使用独立的 PhoneAgent；同一个 PhoneAgent 上的 Run、Step、Reset 互斥执行，可以从多个 goroutine 调用。
 type PhoneAgent struct {
-	visionClient    *model.Client      // 屏幕分析客户端
-	coordClient     *model.Client      // 坐标识别客户端
+	mu              sync.Mutex         // 串行化 Run/Step/Reset 及记录器、传输的替换
+	countMu         sync.Mutex         // 保护 stepCount，GetStepCount 可在任务执行时调用
+	visionClient    *model.Client      // 屏幕分析客户端（视觉角色）
+	coordClient     *model.Client      // 坐标识别客户端（坐标角色）
+	device          adb.Device         // 被控制的设备
 	actionHandler   *actions.ActionHandler
 	config          *AgentConfig
 	decisionModel   *model.DecisionModel // 决策模型
 	decisionConfig  *model.DecisionConfig
-	context         []model.Message
 	stepCount       int
 	actionHistory   []model.ActionHistory
 	currentTask     string // 当前任务
+	lastPlan        *model.PlanResult // 当前步骤的决策结果
+	nextScreenshot  *adb.Screenshot   // 上一步校验时拍摄的截图，下一步直接复用
+	loopDetector    *LoopDetector     // 循环检测，未启用时为 nil
+	loopHint        string            // 下一次决策时附带的循环提示
+	lastApp         string            // 最近启动的应用，重启恢复时使用
+	recorder        *trace.Recorder   // 运行记录器，未启用时为 nil
+	current         *trace.Step       // 当前步骤的记录
+	modelTransport  http.RoundTripper // 模型客户端的 HTTP 传输，nil 为默认
+	events          *EventBus         // 执行事件总线
+	usage           *model.UsageMeter // 三个角色模型调用的 token 用量与费用
 }
 
-// NewPhoneAgentWithDecisionModel 创建带决策模型的 PhoneAgent
+// NewPhoneAgentWithDecisionModel 创建带决策模型的 PhoneAgent，通过 ADB 控制 agentConfig.DeviceID 指定的设备
 func NewPhoneAgentWithDecisionModel(decisionConfig *model.DecisionConfig, agentConfig *AgentConfig, confirmationCallback func(string) bool, takeoverCallback func(string)) *PhoneAgent {
+	if agentConfig == nil {
+		agentConfig = DefaultAgentConfig()
+	}
+	return NewPhoneAgentWithDevice(adb.NewADBDevice(agentConfig.DeviceID), decisionConfig, agentConfig, confirmationCallback, takeoverCallback)
+}
+
+// NewPhoneAgentWithDevice 创建控制指定设备的 PhoneAgent
+func NewPhoneAgentWithDevice(device adb.Device, decisionConfig *model.DecisionConfig, agentConfig *AgentConfig, confirmationCallback func(string) bool, takeoverCallback func(string)) *PhoneAgent {
 	if decisionConfig == nil {
 		decisionConfig = model.DefaultDecisionConfig()
 	}
@@ -34,82 +62,191 @@ func NewPhoneAgentWithDecisionModel(decisionConfig *model.DecisionConfig, agentC
 
 	// 创建两个专门的视觉客户端：屏幕分析和坐标识别
 	visionClient := model.NewClientWithSystemPrompt(decisionConfig.Vision, model.ScreenAnalysisPrompt)
-	coordClient := model.NewClientWithSystemPrompt(decisionConfig.Vision, model.VisionCoordPrompt)
+	coordClient := model.NewClientWithSystemPrompt(decisionConfig.CoordinateConfig(), model.VisionCoordPrompt)
+
+	decisionModel := model.NewDecisionModel(decisionConfig.Decision)
+	usage := model.NewUsageMeter()
+	visionClient.SetUsageMeter(usage, model.RoleVision)
+	coordClient.SetUsageMeter(usage, model.RoleCoordinate)
+	decisionModel.SetUsageMeter(usage)
+
+	var loopDetector *LoopDetector
+	if agentConfig.LoopDetection {
+		loopDetector = NewLoopDetector(agentConfig.LoopWindow, agentConfig.LoopThreshold, agentConfig.RecoveryStrategies)
+	}
 
 	return &PhoneAgent{
 		visionClient:     visionClient,
 		coordClient:      coordClient,
-		actionHandler:    actions.NewActionHandler(agentConfig.DeviceID, confirmationCallback, takeoverCallback),
+		device:           device,
+		actionHandler:    actions.NewActionHandler(device, confirmationCallback, takeoverCallback),
 		config:           agentConfig,
-		decisionModel:    model.NewDecisionModel(decisionConfig.Decision),
+		decisionModel:    decisionModel,
 		decisionConfig:   decisionConfig,
-		context:           []model.Message{},
+		loopDetector:     loopDetector,
+		current:          &trace.Step{},
+		events:           NewEventBus(),
+		usage:            usage,
 		stepCount:         0,
 		actionHistory:     []model.ActionHistory{},
 		currentTask:       "",
 	}
 }
 
-// Run 运行任务
-func (a *PhoneAgent) Run(task string) string {
-	a.context = []model.Message{}
-	a.stepCount = 0
+// Run 运行任务，ctx 取消后当前步骤会尽快中止并返回
+func (a *PhoneAgent) Run(ctx context.Context, task string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.resetRunState()
+	a.setStepCount(0)
 	a.currentTask = task // 保存当前任务
 
 	// 第一步:发送用户任务
-	result := a.executeStep(task, true)
+	result := a.executeStep(ctx, task, true)
 	if result.Finished {
 		return result.Message
 	}
 
 	// 循环执行直到完成或达到最大步数
 	for a.stepCount < a.config.MaxSteps {
-		result = a.executeStep("", false)
+		if err := ctx.Err(); err != nil {
+			message := fmt.Sprintf("Task cancelled: %v", err)
+			a.emit(Event{Type: EventFinished, Message: message})
+			return message
+		}
+		result = a.executeStep(ctx, "", false)
 		if result.Finished {
 			return result.Message
 		}
 	}
 
+	a.emit(Event{Type: EventFinished, Message: "Max steps reached"})
 	return "Max steps reached"
 }
 
 // Step 执行单步
-func (a *PhoneAgent) Step(task string) *StepResult {
-	isFirst := len(a.context) == 0
+func (a *PhoneAgent) Step(ctx context.Context, task string) *StepResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	isFirst := a.stepCount == 0
 
 	if isFirst && task == "" {
 		return &StepResult{Success: false, Finished: true, Message: "Task is required for first step"}
 	}
 
-	// 如果是第一步，保存任务
+	// 如果是第一步，保存任务并清空上一个任务的对话记忆
 	if isFirst && task != "" {
 		a.currentTask = task
+		a.resetRunState()
 	}
 
-	return a.executeStep(task, isFirst)
+	return a.executeStep(ctx, task, isFirst)
 }
 
 // GetStepCount 获取当前步数
 func (a *PhoneAgent) GetStepCount() int {
+	a.countMu.Lock()
+	defer a.countMu.Unlock()
 	return a.stepCount
 }
 
+// setStepCount 更新步数，只在持有 a.mu 的执行 goroutine 中调用
+func (a *PhoneAgent) setStepCount(n int) {
+	a.countMu.Lock()
+	a.stepCount = n
+	a.countMu.Unlock()
+}
+
+// Usage 返回当前（或上一个）任务至今的模型用量与费用，可在任务执行时调用
+func (a *PhoneAgent) Usage() model.UsageSummary {
+	return a.usage.Task()
+}
+
 // Reset 重置 Agent 状态
 func (a *PhoneAgent) Reset() {
-	a.context = []model.Message{}
-	a.stepCount = 0
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.resetRunState()
+	a.setStepCount(0)
 	a.actionHistory = []model.ActionHistory{}
 	a.currentTask = ""
+	a.lastPlan = nil
+}
+
+// resetRunState 清空上一个任务遗留的对话记忆、截图和循环检测状态
+func (a *PhoneAgent) resetRunState() {
+	a.decisionModel.Reset()
+	a.usage.Reset()
+	a.nextScreenshot = nil
+	a.loopHint = ""
+	a.lastApp = ""
+	if a.loopDetector != nil {
+		a.loopDetector.Reset()
+	}
 }
 
 // executeStep 执行单步
-func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
-	a.stepCount++
+func (a *PhoneAgent) executeStep(ctx context.Context, userPrompt string, isFirst bool) (stepResult *StepResult) {
+	if err := ctx.Err(); err != nil {
+		message := fmt.Sprintf("Task cancelled: %v", err)
+		a.emit(Event{Type: EventFinished, Message: message})
+		return &StepResult{
+			Success:  false,
+			Finished: true,
+			Message:  message,
+		}
+	}
 
-	// 截图
-	screenshot, err := adb.GetScreenshot(a.config.DeviceID, 10)
-	if err != nil && a.config.Verbose {
-		fmt.Printf("Screenshot error: %v\n", err)
+	a.setStepCount(a.stepCount + 1)
+	a.current = &trace.Step{Step: a.stepCount, StartedAt: time.Now()}
+	defer func() {
+		stepResult.Usage = a.usage.EndStep()
+		a.recordStep(stepResult)
+		if stepResult.Finished {
+			a.emit(Event{Type: EventFinished, Success: stepResult.Success, Message: stepResult.Message})
+		}
+	}()
+
+	task := a.currentTask
+	if isFirst && userPrompt != "" {
+		task = userPrompt
+	}
+	a.emit(Event{Type: EventStepStarted, Task: task})
+
+	// 截图（上一步校验时已截图则直接复用）
+	screenshot, err := a.nextScreenshot, error(nil)
+	a.nextScreenshot = nil
+	if screenshot == nil {
+		start := time.Now()
+		screenshot, err = a.device.GetScreenshot(ctx, 10)
+		a.current.Timings.Screenshot = time.Since(start).Seconds()
+	}
+	if err != nil {
+		return &StepResult{
+			Success:  false,
+			Finished: true,
+			Message:  fmt.Sprintf("Screenshot error: %v", err),
+		}
+	}
+
+	a.emit(Event{
+		Type:      EventScreenshotCaptured,
+		Width:     screenshot.Width,
+		Height:    screenshot.Height,
+		Sensitive: screenshot.IsSensitive,
+		Duration:  a.current.Timings.Screenshot,
+	})
+
+	// 按配置缩放/压缩截图，减少视觉模型的 token 消耗
+	rawScreenshot := screenshot
+	a.current.Image = rawScreenshot
+	if processed, err := adb.ProcessScreenshot(screenshot, a.config.Screenshot); err == nil {
+		screenshot = processed
+	} else if a.config.Verbose {
+		fmt.Printf("Screenshot process error: %v\n", err)
 	}
 
 	var action map[string]interface{}
@@ -117,12 +254,9 @@ func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
 	var execErr error
 
 	// 执行决策模型模式：决策模型规划，视觉模型执行
-	action, thinking, execErr = a.executeWithDecisionModel(userPrompt, screenshot)
+	action, thinking, execErr = a.executeWithDecisionModel(ctx, userPrompt, screenshot)
 
 	if execErr != nil {
-		if a.config.Verbose {
-			fmt.Printf("Model error: %v\n", execErr)
-		}
 		return &StepResult{
 			Success:  false,
 			Finished: true,
@@ -131,7 +265,32 @@ func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
 	}
 
 	// 执行动作
-	result, err := a.actionHandler.Execute(action, screenshot.Width, screenshot.Height)
+	a.markSensitive(action)
+	executeStart := time.Now()
+	result, err := a.actionHandler.Execute(ctx, action, screenshot.Width, screenshot.Height)
+	a.current.Timings.Execute += time.Since(executeStart).Seconds()
+
+	// 选择器没有匹配到界面元素，回退到视觉模型识别坐标
+	if err == nil && result.SelectorNotFound && a.lastPlan != nil {
+		if a.config.Verbose {
+			fmt.Println("Selector not matched, falling back to vision coordinates")
+		}
+		action, thinking, execErr = a.locateWithVision(ctx, a.lastPlan, screenshot)
+		if execErr != nil {
+			return &StepResult{
+				Success:  false,
+				Finished: true,
+				Message:  fmt.Sprintf("Model error: %v", execErr),
+			}
+		}
+		a.markSensitive(action)
+		executeStart = time.Now()
+		result, err = a.actionHandler.Execute(ctx, action, screenshot.Width, screenshot.Height)
+		a.current.Timings.Execute += time.Since(executeStart).Seconds()
+	}
+	if err != nil {
+		a.current.Error = err.Error()
+	}
 	if err != nil && a.config.Verbose {
 		fmt.Printf("Execute error: %v\n", err)
 		// 创建完成动作
@@ -139,7 +298,13 @@ func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
 			"_metadata": "finish",
 			"message":   err.Error(),
 		}
-		result, _ = a.actionHandler.Execute(action, screenshot.Width, screenshot.Height)
+		result, _ = a.actionHandler.Execute(ctx, action, screenshot.Width, screenshot.Height)
+	}
+
+	// 校验操作是否产生了可见变化
+	note := ""
+	if a.config.VerifyActions && err == nil && result.Success && action["_metadata"] != "finish" && !result.ShouldFinish {
+		a.nextScreenshot, note = a.verifyAction(ctx, action, rawScreenshot)
 	}
 
 	// 记录操作历史
@@ -153,26 +318,39 @@ func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
 	if len(thinking) > 100 {
 		reasonStr = thinking[:100] + "..."
 	}
-	a.actionHistory = append(a.actionHistory, model.ActionHistory{
+	a.current.Action = action
+	a.current.Result = traceResult(result)
+	a.current.Note = note
+	a.emit(Event{
+		Type:     EventActionExecuted,
+		Action:   action,
+		Success:  result.Success,
+		Message:  result.Message,
+		Note:     note,
+		Duration: a.current.Timings.Execute,
+	})
+
+	entry := model.ActionHistory{
 		Action:  actionStr,
 		Reason:  reasonStr,
 		Success: result.Success,
-	})
+		Note:    note,
+	}
+	a.actionHistory = append(a.actionHistory, entry)
+	outcome := result.Message
+	if note != "" {
+		outcome = strings.TrimSpace(outcome + " " + note)
+	}
+	a.decisionModel.RecordOutcome(result.Success, outcome)
 
 	// 检查是否完成
 	finished := action["_metadata"] == "finish" || result.ShouldFinish
 
-	if finished && a.config.Verbose {
-		msg := result.Message
-		if msg == "" {
-			if m, ok := action["message"].(string); ok {
-				msg = m
-			}
+	if !finished {
+		if actionStr == "Launch" && result.Success {
+			a.lastApp, _ = action["app"].(string)
 		}
-		if msg == "" {
-			msg = "Done"
-		}
-		fmt.Printf("✅ 任务完成: %s\n", msg)
+		a.detectLoop(ctx, rawScreenshot, action, entry)
 	}
 
 	return &StepResult{
@@ -185,7 +363,7 @@ func (a *PhoneAgent) executeStep(userPrompt string, isFirst bool) *StepResult {
 }
 
 // executeWithDecisionModel 使用决策模型模式执行
-func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb.Screenshot) (map[string]interface{}, string, error) {
+func (a *PhoneAgent) executeWithDecisionModel(ctx context.Context, userPrompt string, screenshot *adb.Screenshot) (map[string]interface{}, string, error) {
 	// 使用保存的当前任务
 	task := a.currentTask
 
@@ -195,40 +373,28 @@ func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb
 		a.currentTask = userPrompt
 	}
 
-	// 第一步：先调用视觉模型获取屏幕描述
-	screenDescription := ""
-	screenDesc, err := a.analyzeScreen(screenshot)
-	if err != nil {
-		screenDescription = "屏幕分析失败"
-	} else {
-		screenDescription = screenDesc
+	// 第一步：获取屏幕描述（视觉模型和/或界面层级）
+	describeStart := time.Now()
+	screenDescription := a.describeScreen(ctx, screenshot)
+	a.current.Timings.Describe = time.Since(describeStart).Seconds()
+	if a.loopHint != "" {
+		screenDescription = "⚠️ " + a.loopHint + "\n" + screenDescription
+		a.loopHint = ""
 	}
-
-	// 打印视觉模型 → 决策模型的交互内容
-	// if a.config.Verbose {
-	// 	fmt.Println()
-	// 	fmt.Println("📤 视觉模型 → 决策模型 (屏幕描述):")
-	// 	fmt.Printf("%s\n", screenDescription)
-	// 	fmt.Println()
-	// }
+	a.emit(Event{Type: EventScreenAnalyzed, Description: screenDescription, Duration: a.current.Timings.Describe})
 
 	// 第二步：调用决策模型，基于屏幕描述做决策
-	plan, err := a.decisionModel.PlanStep(task, screenDescription, a.stepCount, a.config.MaxSteps, a.actionHistory)
+	a.current.ScreenDescription = screenDescription
+	decisionStart := time.Now()
+	plan, err := a.decisionModel.PlanStep(ctx, task, screenDescription, a.stepCount, a.config.MaxSteps, a.actionHistory)
+	a.current.Timings.Decision = time.Since(decisionStart).Seconds()
 	if err != nil {
 		return nil, "", err
 	}
-
-	// 打印决策模型发出的操作指令
-	// if a.config.Verbose {
-	// 	fmt.Println("操作指令:")
-	// 	fmt.Printf("操作类型: %s\n", plan.ActionType)
-	// 	fmt.Printf("操作原因: %s\n", plan.Reason)
-	// 	if len(plan.Parameters) > 0 {
-	// 		params, _ := json.MarshalIndent(plan.Parameters, "  ", "")
-	// 		fmt.Printf("操作参数: %s\n", string(params))
-	// 	}
-	// 	fmt.Println()
-	// }
+	a.lastPlan = plan
+	a.current.Plan = plan
+	a.current.DecisionOutput = plan.Raw
+	a.emit(Event{Type: EventPlanProduced, Plan: plan, Duration: a.current.Timings.Decision})
 
 	// 检查是否完成
 	if plan.Finished || plan.ActionType == "finish" {
@@ -297,22 +463,66 @@ func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb
 		return action, plan.Thought, nil
 	}
 
-	// 需要视觉解析的操作（Tap, Swipe, DoubleTap, LongPress）
+	// 人工接管直接交给接管回调，不需要坐标
+	if plan.ActionType == "Take_over" {
+		message, _ := plan.Parameters["message"].(string)
+		if message == "" {
+			message = plan.Reason
+		}
+		action = map[string]interface{}{
+			"action":    "Take_over",
+			"message":   message,
+			"_metadata": "do",
+		}
+		return action, plan.Thought, nil
+	}
+
+	// 指定方向的滑动以屏幕中心为中点，不需要视觉模型
+	if plan.ActionType == "Swipe" {
+		direction, _ := plan.Parameters["direction"].(string)
+		if start, end, ok := directionSwipe(direction, []float64{500, 500}); ok {
+			action = map[string]interface{}{
+				"action":    "Swipe",
+				"start":     start,
+				"end":       end,
+				"_metadata": "do",
+			}
+			return action, plan.Thought, nil
+		}
+	}
+
+	// 带选择器的点击类操作：由 ActionHandler 按界面层级定位，未匹配时再回退到视觉模型
+	if plan.ActionType == "Tap" || plan.ActionType == "DoubleTap" || plan.ActionType == "LongPress" {
+		if sel, ok := planSelector(plan); ok {
+			action = map[string]interface{}{
+				"action":    plan.ActionType,
+				"selector":  sel,
+				"_metadata": "do",
+			}
+			return action, plan.Thought, nil
+		}
+	}
+
+	return a.locateWithVision(ctx, plan, screenshot)
+}
+
+// locateWithVision 调用坐标识别模型，为需要坐标的操作（Tap, Swipe, DoubleTap, LongPress）构建动作
+func (a *PhoneAgent) locateWithVision(ctx context.Context, plan *model.PlanResult, screenshot *adb.Screenshot) (map[string]interface{}, string, error) {
 	// 使用专门的坐标识别客户端
 	description := a.getVisionDescription(plan)
-	visionContext := []model.Message{
-		model.CreateUserMessage(description, screenshot.Base64Data),
-	}
 	model.LogStart("视觉坐标分析提示词")
 	model.LogContent(*a.coordClient.SystemPrompt)
-	model.LogContent(visionContext[0])
+	model.LogContent(description)
 	model.LogEnd("视觉坐标分析提示词")
 
 	// 调用视觉模型获取坐标
-	response, err := a.coordClient.Request(visionContext)
+	visionStart := time.Now()
+	response, err := a.requestVision(ctx, a.coordClient, model.RoleCoordinate, description, screenshot)
+	a.current.Timings.Vision += time.Since(visionStart).Seconds()
 	if err != nil {
 		return nil, "", err
 	}
+	a.current.VisionOutput = response.RawContent
 
 	model.LogStart("视觉坐标模型输出")
 	model.LogContent(response)
@@ -323,6 +533,12 @@ func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb
 	if err != nil {
 		return nil, "", err
 	}
+	a.emit(Event{
+		Type:         EventCoordinatesResolved,
+		Coordinates:  coordinates,
+		VisionOutput: response.RawContent,
+		Duration:     time.Since(visionStart).Seconds(),
+	})
 
 	// 构建完整的操作：决策模型的操作类型 + 视觉模型的坐标
 	visionAction := map[string]interface{}{
@@ -338,43 +554,9 @@ func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb
 		}
 		visionAction["element"] = coordinates[0]
 	case "Swipe":
-		if len(coordinates) == 0 {
-			return nil, "", fmt.Errorf("未返回任何坐标")
-		}
-		// 如果只返回了一个坐标，根据描述推断另一个坐标
-		if len(coordinates) == 1 {
-			startCoord := coordinates[0]
-			var endCoord []float64
-
-			// 根据描述推断滑动方向
-			reason := strings.ToLower(plan.Reason)
-			if strings.Contains(reason, "从右向左") || strings.Contains(reason, "向左滑") {
-				// 从右向左：终点 x 减小
-				endCoord = []float64{startCoord[0] - 300, startCoord[1]}
-			} else if strings.Contains(reason, "从左向右") || strings.Contains(reason, "向右滑") {
-				// 从左向右：终点 x 增大
-				endCoord = []float64{startCoord[0] + 300, startCoord[1]}
-			} else if strings.Contains(reason, "从下往上") || strings.Contains(reason, "向上滑") {
-				// 从下往上：终点 y 减小
-				endCoord = []float64{startCoord[0], startCoord[1] - 300}
-			} else if strings.Contains(reason, "从上往下") || strings.Contains(reason, "向下滑") {
-				// 从上往下：终点 y 增大
-				endCoord = []float64{startCoord[0], startCoord[1] + 300}
-			} else {
-				// 默认：从右向左滑动
-				endCoord = []float64{startCoord[0] - 300, startCoord[1]}
-			}
-
-			// 确保坐标在有效范围内
-			for i := 0; i < 2; i++ {
-				if endCoord[i] < 0 {
-					endCoord[i] = 0
-				} else if endCoord[i] > 1000 {
-					endCoord[i] = 1000
-				}
-			}
-
-			coordinates = append(coordinates, endCoord)
+		// 带有效 direction 的滑动不会走到这里，只能使用视觉模型返回的起点和终点
+		if len(coordinates) < 2 {
+			return nil, "", fmt.Errorf("滑动缺少有效的 direction 参数，视觉模型也未返回起点和终点")
 		}
 		visionAction["start"] = coordinates[0]
 		visionAction["end"] = coordinates[1]
@@ -383,18 +565,74 @@ func (a *PhoneAgent) executeWithDecisionModel(userPrompt string, screenshot *adb
 	return visionAction, plan.Thought, nil
 }
 
-// analyzeScreen 使用视觉模型分析屏幕，返回屏幕描述
-func (a *PhoneAgent) analyzeScreen(screenshot *adb.Screenshot) (string, error) {
-	// 使用专门的屏幕分析客户端（系统提示词已缓存）
-	messages := []model.Message{
-		model.CreateUserMessage("描述屏幕内容", screenshot.Base64Data),
+// swipeSpan 按方向滑动时起点到终点的距离（0-1000 归一化坐标）
+const swipeSpan = 500
+
+// directionSwipe 以 center 为中点构建 direction 方向的滑动起点和终点，direction 无效时返回 false
+//
+// direction 是手指移动的方向：up 表示从下往上滑，down 表示从上往下滑。
+func directionSwipe(direction string, center []float64) ([]float64, []float64, bool) {
+	var dx, dy float64
+	switch direction {
+	case "up":
+		dy = -swipeSpan
+	case "down":
+		dy = swipeSpan
+	case "left":
+		dx = -swipeSpan
+	case "right":
+		dx = swipeSpan
+	default:
+		return nil, nil, false
 	}
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1000, v)) }
+	start := []float64{clamp(center[0] - dx/2), clamp(center[1] - dy/2)}
+	end := []float64{clamp(center[0] + dx/2), clamp(center[1] + dy/2)}
+	return start, end, true
+}
 
+// describeScreen 根据配置的屏幕来源生成给决策模型的屏幕描述
+func (a *PhoneAgent) describeScreen(ctx context.Context, screenshot *adb.Screenshot) string {
+	// 界面层级：原生应用可以拿到精确的元素文字和位置
+	uiText := ""
+	if a.config.ScreenSource == ScreenSourceUI || a.config.ScreenSource == ScreenSourceHybrid {
+		root, err := a.device.DumpUI(ctx)
+		if err != nil {
+			if a.config.Verbose {
+				fmt.Printf("UI dump error: %v\n", err)
+			}
+		} else {
+			uiText = adb.RenderUITree(root, screenshot.Width, screenshot.Height)
+		}
+	}
+
+	// 纯界面层级模式，dump 成功时不再调用视觉模型
+	if a.config.ScreenSource == ScreenSourceUI && uiText != "" {
+		return "界面元素（坐标为0-1000归一化中心点）:\n" + uiText
+	}
+
+	screenDescription := ""
+	screenDesc, err := a.analyzeScreen(ctx, screenshot)
+	if err != nil {
+		screenDescription = "屏幕分析失败"
+	} else {
+		screenDescription = screenDesc
+	}
+
+	if uiText != "" {
+		screenDescription += "\n\n界面元素（坐标为0-1000归一化中心点）:\n" + uiText
+	}
+	return screenDescription
+}
+
+// analyzeScreen 使用视觉模型分析屏幕，返回屏幕描述
+func (a *PhoneAgent) analyzeScreen(ctx context.Context, screenshot *adb.Screenshot) (string, error) {
+	// 使用专门的屏幕分析客户端（系统提示词已缓存）
 	model.LogStart("屏幕内容分析提示词")
 	model.LogContent(*a.visionClient.SystemPrompt)
 	model.LogEnd("屏幕内容分析提示词")
 
-	response, err := a.visionClient.Request(messages)
+	response, err := a.requestVision(ctx, a.visionClient, model.RoleVision, "描述屏幕内容", screenshot)
 	if err != nil {
 		return "", err
 	}
@@ -402,6 +640,41 @@ func (a *PhoneAgent) analyzeScreen(screenshot *adb.Screenshot) (string, error) {
 	return response.RawContent, nil
 }
 
+// requestVision 发送“截图 + 提示词”给视觉客户端，配置了视觉缓存时先查缓存
+//
+// 敏感页面的截图不读写缓存，故障转移到备用模型时的响应不写入缓存。
+func (a *PhoneAgent) requestVision(ctx context.Context, client *model.Client, role string, prompt string, screenshot *adb.Screenshot) (*model.ModelResponse, error) {
+	messages := []model.Message{
+		model.CreateImageMessage(prompt, screenshot.Base64Data, screenshot.MimeType),
+	}
+	visionCache := a.config.VisionCache
+	if visionCache == nil || screenshot.IsSensitive {
+		return client.Request(ctx, messages)
+	}
+
+	key := visionCache.Key(client.ModelName(), client.SystemPromptText()+"\x00"+prompt, screenshot)
+	if content, ok := visionCache.Get(key); ok {
+		a.usage.RecordCacheHit(role)
+		if a.config.Verbose {
+			fmt.Printf("💾 视觉缓存命中（%s）\n", role)
+		}
+		return &model.ModelResponse{RawContent: content}, nil
+	}
+
+	response, err := client.Request(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	// 缓存键使用首选模型名称
+	if response.Model != client.ModelName() {
+		return response, nil
+	}
+	if err := visionCache.Put(key, response.RawContent); err != nil && a.config.Verbose {
+		fmt.Printf("Vision cache error: %v\n", err)
+	}
+	return response, nil
+}
+
 // getVisionDescription 获取视觉模型的目标描述
 func (a *PhoneAgent) getVisionDescription(plan *model.PlanResult) string {
 	// 根据操作类型和原因构建具体的描述
@@ -419,6 +692,47 @@ func (a *PhoneAgent) getVisionDescription(plan *model.PlanResult) string {
 	}
 }
 
+// planSelector 从决策模型参数中提取界面元素选择器
+func planSelector(plan *model.PlanResult) (*actions.Selector, bool) {
+	if sel, ok := actions.ParseSelector(plan.Parameters["selector"]); ok {
+		return sel, true
+	}
+	return actions.ParseSelector(plan.Parameters)
+}
+
+// markSensitive 点击类操作的计划命中敏感关键词时为动作附上确认消息，执行前由确认回调决定是否继续
+func (a *PhoneAgent) markSensitive(action map[string]interface{}) {
+	if a.lastPlan == nil {
+		return
+	}
+	switch action["action"] {
+	case "Tap", "DoubleTap", "LongPress":
+		if message := sensitiveMessage(a.lastPlan, a.config.SensitiveKeywords); message != "" {
+			action["message"] = message
+		}
+	}
+}
+
+// sensitiveMessage 计划的 reason、target 或 selector 文字命中关键词时返回给人工的确认消息，否则返回空字符串
+func sensitiveMessage(plan *model.PlanResult, keywords []string) string {
+	target, _ := plan.Parameters["target"].(string)
+	if sel, ok := planSelector(plan); ok && target == "" {
+		target = strings.TrimSpace(sel.Text + " " + sel.ContentDesc)
+	}
+
+	text := strings.ToLower(plan.Reason + "\n" + target)
+	for _, keyword := range keywords {
+		if keyword == "" || !strings.Contains(text, strings.ToLower(keyword)) {
+			continue
+		}
+		if target != "" {
+			return fmt.Sprintf("%s「%s」：%s", plan.ActionType, target, plan.Reason)
+		}
+		return fmt.Sprintf("%s：%s", plan.ActionType, plan.Reason)
+	}
+	return ""
+}
+
 // parseVisionCoordinates 解析视觉模型返回的纯坐标
 func parseVisionCoordinates(content string, verbose bool) ([][]float64, error) {
 	// 去除所有换行符和空格
@@ -503,6 +817,7 @@ type StepResult struct {
 	Action   map[string]interface{}
 	Thinking string
 	Message  string
+	Usage    model.UsageSummary // 本步模型用量与费用
 }
 
 // removeImagesFromMessages 从消息中移除图片
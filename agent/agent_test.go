@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/approval"
+	"go-phone-agent/model"
+)
+
+// modelStub 按模型名称依次返回预置回复的 OpenAI 兼容接口，回复用完后重复最后一条
+type modelStub struct {
+	mu       sync.Mutex
+	replies  map[string][]string
+	requests map[string]int
+}
+
+func newModelStub(replies map[string][]string) *modelStub {
+	return &modelStub{replies: replies, requests: map[string]int{}}
+}
+
+func (s *modelStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	replies := s.replies[body.Model]
+	n := s.requests[body.Model]
+	s.requests[body.Model]++
+	s.mu.Unlock()
+
+	content := ""
+	if len(replies) > 0 {
+		content = replies[min(n, len(replies)-1)]
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{{"message": map[string]string{"content": content}, "finish_reason": "stop"}},
+	})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+// count 某个模型收到的请求数
+func (s *modelStub) count(modelName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[modelName]
+}
+
+// stubConfig 决策、视觉、坐标三个角色分别使用 decision、vision、coord 模型
+func stubConfig(outputMode string) *model.DecisionConfig {
+	newConfig := func(name string) *model.ModelConfig {
+		return &model.ModelConfig{BaseURL: "http://models.test/v1", ModelName: name, DisableStream: true, OutputMode: outputMode}
+	}
+	return &model.DecisionConfig{Decision: newConfig("decision"), Vision: newConfig("vision"), Coordinate: newConfig("coord")}
+}
+
+// newStubAgent 创建使用假设备和预置模型回复的 agent
+func newStubAgent(device adb.Device, outputMode string, stub *modelStub, confirm func(string) bool, takeover func(string)) *PhoneAgent {
+	agentConfig := DefaultAgentConfig()
+	agentConfig.Verbose = false
+	agentConfig.VerifyActions = false
+	agentConfig.LoopDetection = false
+	a := NewPhoneAgentWithDevice(device, stubConfig(outputMode), agentConfig, confirm, takeover)
+	a.SetModelTransport(stub)
+	return a
+}
+
+func TestStepSwipeDirection(t *testing.T) {
+	tests := []struct {
+		direction string
+		want      string
+	}{
+		{"up", "Swipe(540,1800,540,600,0)"},
+		{"down", "Swipe(540,600,540,1800,0)"},
+		{"left", "Swipe(810,1200,270,1200,0)"},
+		{"right", "Swipe(270,1200,810,1200,0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.direction, func(t *testing.T) {
+			device := adb.NewFakeDevice("emulator-5554")
+			stub := newModelStub(map[string][]string{
+				"vision":   {"列表页面"},
+				"decision": {`<thought>查看更多</thought><action>Swipe</action><parameters>{"direction":"` + tt.direction + `"}</parameters><reason>从右向左滑动</reason>`},
+			})
+			a := newStubAgent(device, model.OutputModeTags, stub, nil, nil)
+
+			if result := a.Step(context.Background(), "浏览列表"); !result.Success {
+				t.Fatalf("step failed: %s", result.Message)
+			}
+			if !reflect.DeepEqual(device.Calls, []string{tt.want}) {
+				t.Errorf("calls = %v, want [%s]", device.Calls, tt.want)
+			}
+			if stub.count("coord") != 0 {
+				t.Errorf("coordinate model called %d times, want none", stub.count("coord"))
+			}
+		})
+	}
+}
+
+func TestStepTakeoverMessage(t *testing.T) {
+	device := adb.NewFakeDevice("emulator-5554")
+	stub := newModelStub(map[string][]string{
+		"vision":   {"登录页面，需要输入短信验证码"},
+		"decision": {`<thought>需要验证码</thought><action>Take_over</action><parameters>{"message":"请输入短信验证码"}</parameters><reason>需要用户协助</reason>`},
+	})
+	var got []string
+	a := newStubAgent(device, model.OutputModeTags, stub, nil, func(message string) { got = append(got, message) })
+
+	if result := a.Step(context.Background(), "登录账号"); !result.Success {
+		t.Fatalf("step failed: %s", result.Message)
+	}
+	if !reflect.DeepEqual(got, []string{"请输入短信验证码"}) {
+		t.Errorf("takeover messages = %v, want the plan's message", got)
+	}
+	if stub.count("coord") != 0 {
+		t.Errorf("coordinate model called %d times, want none", stub.count("coord"))
+	}
+}
+
+func TestStepJSONSchemaSwipe(t *testing.T) {
+	device := adb.NewFakeDevice("emulator-5554")
+	stub := newModelStub(map[string][]string{
+		"vision":   {"聊天列表"},
+		"decision": {`{"thought":"刷新列表","action_type":"Swipe","parameters":{"direction":"down"},"reason":"下拉刷新"}`},
+	})
+	a := newStubAgent(device, model.OutputModeJSONSchema, stub, nil, nil)
+
+	if result := a.Step(context.Background(), "刷新聊天列表"); !result.Success {
+		t.Fatalf("step failed: %s", result.Message)
+	}
+	// 手指从上往下移动
+	want := []string{"Swipe(540,600,540,1800,0)"}
+	if !reflect.DeepEqual(device.Calls, want) {
+		t.Errorf("calls = %v, want %v", device.Calls, want)
+	}
+}
+
+// recordingApprover 记录收到的审批请求并返回固定决定
+type recordingApprover struct {
+	mu       sync.Mutex
+	decision approval.Decision
+	requests []approval.Request
+}
+
+func (r *recordingApprover) Request(ctx context.Context, req approval.Request) (approval.Decision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	if req.Kind == approval.KindTakeover {
+		return approval.Resume, nil
+	}
+	return r.decision, nil
+}
+
+func TestStepApproval(t *testing.T) {
+	tests := []struct {
+		name      string
+		plan      string
+		decision  approval.Decision
+		wantKind  approval.Kind // 为空表示不应请求审批
+		wantCalls []string
+	}{
+		{"payment approved", `<action>Tap</action><parameters>{"target":"确认支付按钮"}</parameters><reason>点击底部按钮完成付款</reason>`,
+			approval.Approve, approval.KindConfirmation, []string{"Tap(540,2160)"}},
+		{"payment denied", `<action>Tap</action><parameters>{"selector":{"text":"立即支付"}}</parameters><reason>点击按钮</reason>`,
+			approval.Deny, approval.KindConfirmation, nil},
+		{"ordinary tap", `<action>Tap</action><parameters>{"target":"搜索框"}</parameters><reason>点击顶部搜索框</reason>`,
+			approval.Deny, "", []string{"Tap(540,2160)"}},
+		{"takeover", `<action>Take_over</action><parameters>{"message":"请完成人脸验证"}</parameters><reason>需要人脸验证</reason>`,
+			approval.Deny, approval.KindTakeover, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := adb.NewFakeDevice("emulator-5554")
+			stub := newModelStub(map[string][]string{
+				"vision":   {"订单确认页面，底部有立即支付按钮"},
+				"coord":    {"<answer>[500,900]</answer>"},
+				"decision": {"<thought>下一步</thought>" + tt.plan},
+			})
+			approver := &recordingApprover{decision: tt.decision}
+			confirm, takeover := approval.Callbacks(approver, approval.DefaultPolicy(), device.ID())
+			a := newStubAgent(device, model.OutputModeTags, stub, confirm, takeover)
+
+			a.Step(context.Background(), "购买购物车中的商品")
+
+			if tt.wantKind == "" {
+				if len(approver.requests) != 0 {
+					t.Errorf("approver received %+v, want no request", approver.requests)
+				}
+			} else {
+				if len(approver.requests) != 1 {
+					t.Fatalf("approver received %d requests, want 1", len(approver.requests))
+				}
+				req := approver.requests[0]
+				if req.Kind != tt.wantKind || req.Device != "emulator-5554" || req.Message == "" {
+					t.Errorf("request = %+v, want %s for emulator-5554", req, tt.wantKind)
+				}
+				if req.Kind == approval.KindConfirmation && !req.Sensitive {
+					t.Errorf("payment confirmation not marked sensitive: %+v", req)
+				}
+			}
+			if !reflect.DeepEqual(device.Calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", device.Calls, tt.wantCalls)
+			}
+		})
+	}
+}
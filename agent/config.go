@@ -1,11 +1,41 @@
 package agent
 
+import (
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/approval"
+	"go-phone-agent/cache"
+)
+
+// 屏幕描述来源
+const (
+	ScreenSourceVision = "vision" // 仅视觉模型描述屏幕（默认）
+	ScreenSourceUI     = "ui"     // 仅使用 uiautomator 界面层级，失败时回退到视觉模型
+	ScreenSourceHybrid = "hybrid" // 视觉模型描述 + 界面层级
+)
+
 // AgentConfig 配置 PhoneAgent 的行为
 type AgentConfig struct {
 	MaxSteps     int    // 每个任务最大步数
 	DeviceID     string // ADB 设备 ID,为空则自动检测
 	SystemPrompt string // 自定义系统提示词
 	Verbose      bool   // 是否打印调试信息
+
+	Screenshot   adb.ScreenshotOptions // 截图发送给视觉模型前的处理选项
+	ScreenSource string                // 屏幕描述来源：vision/ui/hybrid
+
+	VerifyActions bool          // 操作后对比前后截图，检测无效点击
+	VerifyDelay   time.Duration // 操作后等待界面稳定再截图
+
+	LoopDetection      bool     // 检测同一屏幕上的重复操作并自动恢复
+	LoopWindow         int      // 循环检测窗口（步数）
+	LoopThreshold      int      // 窗口内同一屏幕同一操作重复多少次视为循环
+	RecoveryStrategies []string // 恢复策略顺序：hint/back/relaunch/takeover
+
+	VisionCache *cache.Cache // 屏幕分析和坐标识别的响应缓存，nil 表示不缓存；可在多个 agent 间共享
+
+	SensitiveKeywords []string // 点击类操作的 reason、target 或 selector 命中关键词时先经确认回调确认
 }
 
 // DefaultAgentConfig 返回默认配置
@@ -15,5 +45,16 @@ func DefaultAgentConfig() *AgentConfig {
 		DeviceID:     "",
 		SystemPrompt: "",
 		Verbose:      true,
+		ScreenSource: ScreenSourceVision,
+
+		VerifyActions: true,
+		VerifyDelay:   500 * time.Millisecond,
+
+		LoopDetection:      true,
+		LoopWindow:         8,
+		LoopThreshold:      3,
+		RecoveryStrategies: DefaultRecoveryStrategies(),
+
+		SensitiveKeywords: approval.DefaultSensitiveKeywords(),
 	}
 }
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"go-phone-agent/model"
+)
+
+// EventType 事件类型
+type EventType string
+
+// agent 执行过程中发出的事件
+const (
+	EventStepStarted         EventType = "step_started"         // 开始新的一步
+	EventScreenshotCaptured  EventType = "screenshot_captured"  // 截图完成
+	EventScreenAnalyzed      EventType = "screen_analyzed"      // 屏幕描述生成完成
+	EventPlanProduced        EventType = "plan_produced"        // 决策模型给出计划
+	EventCoordinatesResolved EventType = "coordinates_resolved" // 视觉模型返回坐标
+	EventActionExecuted      EventType = "action_executed"      // 动作执行完成
+	EventFinished            EventType = "finished"             // 任务结束（完成、出错或取消）
+)
+
+// Event agent 事件，按 Type 填充对应字段
+type Event struct {
+	Type   EventType `json:"type"`
+	Step   int       `json:"step"`
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`
+
+	Task         string                 `json:"task,omitempty"`          // step_started
+	Width        int                    `json:"width,omitempty"`         // screenshot_captured
+	Height       int                    `json:"height,omitempty"`        // screenshot_captured
+	Sensitive    bool                   `json:"sensitive,omitempty"`     // screenshot_captured
+	Description  string                 `json:"description,omitempty"`   // screen_analyzed
+	Plan         *model.PlanResult      `json:"plan,omitempty"`          // plan_produced
+	Coordinates  [][]float64            `json:"coordinates,omitempty"`   // coordinates_resolved（0-1000 归一化）
+	VisionOutput string                 `json:"vision_output,omitempty"` // coordinates_resolved
+	Action       map[string]interface{} `json:"action,omitempty"`        // action_executed
+	Success      bool                   `json:"success"`                 // action_executed、finished
+	Message      string                 `json:"message,omitempty"`       // action_executed、finished
+	Note         string                 `json:"note,omitempty"`          // action_executed 校验备注
+	Duration     float64                `json:"duration,omitempty"`      // 该阶段耗时（秒）
+	Usage        *model.UsageSummary    `json:"usage,omitempty"`         // finished 任务累计模型用量与费用
+}
+
+// EventBus 同步分发 agent 事件，处理函数在 agent 所在的 goroutine 中按顺序调用
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers []subscriber
+	nextID      int
+}
+
+// subscriber 事件订阅者
+type subscriber struct {
+	id      int
+	handler func(Event)
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe 订阅事件，返回取消订阅函数；处理函数不应阻塞
+func (b *EventBus) Subscribe(handler func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers = append(b.subscribers, subscriber{id: id, handler: handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subscribers {
+			if sub.id == id {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish 按订阅顺序把事件分发给所有处理函数
+func (b *EventBus) Publish(event Event) {
+	b.mu.Lock()
+	subscribers := b.subscribers
+	b.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.handler(event)
+	}
+}
+
+// Events 返回 agent 的事件总线
+func (a *PhoneAgent) Events() *EventBus {
+	return a.events
+}
+
+// emit 补全步数、时间和设备后发布事件，finished 事件附带任务累计用量
+func (a *PhoneAgent) emit(event Event) {
+	event.Step = a.stepCount
+	event.Time = time.Now()
+	event.Device = a.device.ID()
+	if event.Type == EventFinished {
+		usage := a.usage.Task()
+		event.Usage = &usage
+	}
+	a.events.Publish(event)
+}
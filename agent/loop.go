@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/model"
+)
+
+// 卡死恢复策略，按配置顺序逐级升级
+const (
+	RecoveryHint     = "hint"     // 提示决策模型正在循环
+	RecoveryBack     = "back"     // 按返回键
+	RecoveryRelaunch = "relaunch" // 回到桌面并重新启动当前应用
+	RecoveryTakeover = "takeover" // 请求人工接管
+)
+
+// DefaultRecoveryStrategies 默认恢复策略顺序
+func DefaultRecoveryStrategies() []string {
+	return []string{RecoveryHint, RecoveryBack, RecoveryRelaunch, RecoveryTakeover}
+}
+
+// loopHashDistance 两个屏幕指纹距离不超过该值视为同一屏幕
+const loopHashDistance = 3
+
+// loopEntry 一步操作的屏幕指纹和操作签名
+type loopEntry struct {
+	screen    uint64
+	signature string               // 操作类型和坐标以外的参数
+	points    map[string][]float64 // element/start/end 坐标
+	noChange  bool
+}
+
+// LoopDetector 根据屏幕指纹和操作历史检测循环，并给出逐级升级的恢复策略
+type LoopDetector struct {
+	window     int      // 检测窗口（最近多少步）
+	threshold  int      // 同一屏幕上同一操作重复多少次视为循环
+	strategies []string // 恢复策略顺序
+	entries    []loopEntry
+	level      int // 下一次使用的策略序号
+	calmSteps  int // 距上次检测到循环的步数
+}
+
+// NewLoopDetector 创建循环检测器，window/threshold<=0 时使用默认值
+func NewLoopDetector(window, threshold int, strategies []string) *LoopDetector {
+	if window <= 0 {
+		window = 8
+	}
+	if threshold <= 1 {
+		threshold = 3
+	}
+	if len(strategies) == 0 {
+		strategies = DefaultRecoveryStrategies()
+	}
+	return &LoopDetector{
+		window:     window,
+		threshold:  threshold,
+		strategies: strategies,
+	}
+}
+
+// Reset 清空检测状态
+func (d *LoopDetector) Reset() {
+	d.entries = nil
+	d.level = 0
+	d.calmSteps = 0
+}
+
+// Observe 记录一步操作，检测到循环时返回应执行的恢复策略，否则返回空字符串
+//
+// 同一屏幕上的同一操作在窗口内重复 threshold 次视为循环；操作后屏幕无变化
+// （ActionHistory.Note 为 NoVisibleChange）的步骤按两次计，更快触发恢复。
+func (d *LoopDetector) Observe(screen uint64, action map[string]interface{}, history model.ActionHistory) string {
+	signature, points := splitAction(action)
+	entry := loopEntry{
+		screen:    screen,
+		signature: signature,
+		points:    points,
+		noChange:  history.Note == NoVisibleChange,
+	}
+	d.entries = append(d.entries, entry)
+	if len(d.entries) > d.window {
+		d.entries = d.entries[len(d.entries)-d.window:]
+	}
+
+	count := 0
+	for _, e := range d.entries {
+		if !e.sameAction(entry) || adb.HammingDistance(e.screen, entry.screen) > loopHashDistance {
+			continue
+		}
+		count++
+		if e.noChange {
+			count++
+		}
+	}
+
+	if count < d.threshold {
+		// 连续一个窗口没有循环，说明已恢复正常，策略从头开始
+		d.calmSteps++
+		if d.calmSteps >= d.window {
+			d.level = 0
+		}
+		return ""
+	}
+
+	strategy := d.strategies[min(d.level, len(d.strategies)-1)]
+	d.level++
+	d.calmSteps = 0
+	d.entries = nil
+	return strategy
+}
+
+// loopCoordTolerance 两次操作的坐标在 x、y 上都相差不超过该值（0-1000 归一化坐标）时视为同一位置，
+// 视觉模型对同一控件多次给出的坐标会相差几个单位
+const loopCoordTolerance = 20
+
+// sameAction 两步是否为同一操作：坐标以外的参数相同，且每个坐标都在 loopCoordTolerance 以内
+func (e loopEntry) sameAction(other loopEntry) bool {
+	if e.signature != other.signature || len(e.points) != len(other.points) {
+		return false
+	}
+	for key, p := range e.points {
+		q, ok := other.points[key]
+		if !ok || len(p) != len(q) {
+			return false
+		}
+		for i := range p {
+			if math.Abs(p[i]-q[i]) > loopCoordTolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitAction 把操作拆成签名和坐标：签名为坐标以外的参数（json.Marshal 对 map 键排序，结果稳定）
+func splitAction(action map[string]interface{}) (string, map[string][]float64) {
+	params := map[string]interface{}{}
+	points := map[string][]float64{}
+	for k, v := range action {
+		switch k {
+		case "_metadata":
+		case "element", "start", "end":
+			if coords, ok := coordinates(v); ok {
+				points[k] = coords
+			} else {
+				params[k] = v
+			}
+		default:
+			params[k] = v
+		}
+	}
+	data, _ := json.Marshal(params)
+	return string(data), points
+}
+
+// coordinates 把坐标 [x, y] 转换为 []float64，不是坐标时返回 false
+func coordinates(value interface{}) ([]float64, bool) {
+	switch v := value.(type) {
+	case []float64:
+		return v, true
+	case []int:
+		coords := make([]float64, len(v))
+		for i, c := range v {
+			coords[i] = float64(c)
+		}
+		return coords, true
+	case []interface{}:
+		coords := make([]float64, len(v))
+		for i, c := range v {
+			f, ok := c.(float64)
+			if !ok {
+				return nil, false
+			}
+			coords[i] = f
+		}
+		return coords, true
+	}
+	return nil, false
+}
+
+// detectLoop 用执行前截图的指纹和本步操作更新循环检测，检测到循环时执行恢复策略
+func (a *PhoneAgent) detectLoop(ctx context.Context, screenshot *adb.Screenshot, action map[string]interface{}, entry model.ActionHistory) {
+	if a.loopDetector == nil || screenshot == nil || screenshot.IsSensitive {
+		return
+	}
+	hash, err := adb.DHash(screenshot)
+	if err != nil {
+		return
+	}
+	if strategy := a.loopDetector.Observe(hash, action, entry); strategy != "" {
+		a.recoverFromLoop(ctx, strategy, action)
+	}
+}
+
+// recoverFromLoop 执行恢复策略
+func (a *PhoneAgent) recoverFromLoop(ctx context.Context, strategy string, action map[string]interface{}) {
+	actionType, _ := action["action"].(string)
+	if a.config.Verbose {
+		fmt.Printf("🔁 检测到重复操作 %s，恢复策略: %s\n", actionType, strategy)
+	}
+
+	var err error
+	switch strategy {
+	case RecoveryHint:
+		a.loopHint = fmt.Sprintf("检测到你在同一屏幕上重复执行 %s 且没有进展，请换一个目标或换一种方式（如滑动查找、返回上一页）。", actionType)
+	case RecoveryBack:
+		err = a.device.Back(ctx)
+		a.loopHint = "检测到循环，已自动按返回键，请根据新屏幕重新规划。"
+	case RecoveryRelaunch:
+		err = a.relaunchApp(ctx)
+		a.loopHint = "检测到循环，已重新启动应用，请根据新屏幕重新规划。"
+	case RecoveryTakeover:
+		_, err = a.actionHandler.Execute(ctx, map[string]interface{}{
+			"_metadata": "do",
+			"action":    "Take_over",
+			"message":   fmt.Sprintf("Agent 在同一屏幕上重复执行 %s，请手动处理后继续", actionType),
+		}, 0, 0)
+		a.loopHint = "检测到循环，用户已人工处理，请根据新屏幕重新规划。"
+	}
+
+	if err != nil && a.config.Verbose {
+		fmt.Printf("Recovery error: %v\n", err)
+	}
+	// 恢复操作改变了屏幕，校验时拍的截图不能再复用
+	if strategy != RecoveryHint {
+		a.nextScreenshot = nil
+	}
+}
+
+// relaunchApp 回到桌面并重新启动最近启动的应用（无记录时使用当前前台应用）
+func (a *PhoneAgent) relaunchApp(ctx context.Context) error {
+	app := a.lastApp
+	if app == "" {
+		app = a.device.GetCurrentApp(ctx)
+	}
+	if err := a.device.Home(ctx); err != nil {
+		return err
+	}
+	if app == "" || app == "System Home" {
+		return nil
+	}
+	_, err := a.device.LaunchApp(ctx, app)
+	return err
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"testing"
+
+	"go-phone-agent/model"
+)
+
+func tap(x, y float64) map[string]interface{} {
+	return map[string]interface{}{"_metadata": "do", "action": "Tap", "element": []float64{x, y}}
+}
+
+func TestLoopDetectorNearbyTaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []map[string]interface{}
+		want    string
+	}{
+		{"same control, jittered coordinates", []map[string]interface{}{tap(500, 500), tap(503, 497), tap(498, 506)}, RecoveryHint},
+		{"coordinates decoded from JSON", []map[string]interface{}{
+			tap(500, 500),
+			{"action": "Tap", "element": []interface{}{504.0, 495.0}},
+			tap(496, 503),
+		}, RecoveryHint},
+		// 509 和 511 落在固定分格的两侧，按距离比较仍是同一控件
+		{"jitter across a grid line", []map[string]interface{}{tap(509, 311), tap(511, 309), tap(510, 310)}, RecoveryHint},
+		{"drifting taps", []map[string]interface{}{tap(500, 500), tap(530, 500), tap(560, 500)}, ""},
+		{"different controls", []map[string]interface{}{tap(500, 500), tap(500, 620), tap(200, 500)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewLoopDetector(8, 3, nil)
+			got := ""
+			for _, action := range tt.actions {
+				got = d.Observe(0x0f0f, action, model.ActionHistory{Action: "Tap"})
+			}
+			if got != tt.want {
+				t.Errorf("strategy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopDetectorDifferentScreens(t *testing.T) {
+	d := NewLoopDetector(8, 3, nil)
+	screens := []uint64{0x0000, 0xffff, 0xff0000}
+	for _, screen := range screens {
+		if got := d.Observe(screen, tap(500, 500), model.ActionHistory{}); got != "" {
+			t.Fatalf("strategy = %q on different screens, want none", got)
+		}
+	}
+}
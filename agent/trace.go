@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-phone-agent/actions"
+	"go-phone-agent/trace"
+)
+
+// SetRecorder 设置运行记录器，之后每一步（截图、模型输出、动作、结果、耗时）都会写入记录目录；
+// 模型的原始响应也会被录制，用于回放。传入 nil 停止记录。
+func (a *PhoneAgent) SetRecorder(recorder *trace.Recorder) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.recorder = recorder
+
+	var transport http.RoundTripper
+	if recorder != nil {
+		transport = recorder.Transport(a.modelTransport)
+	}
+	a.applyTransport(transport)
+}
+
+// SetModelTransport 替换所有模型客户端的 HTTP 传输（如 trace.ReplayTransport 回放记录的响应），nil 恢复默认
+func (a *PhoneAgent) SetModelTransport(rt http.RoundTripper) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.modelTransport = rt
+	if a.recorder != nil {
+		a.applyTransport(a.recorder.Transport(rt))
+		return
+	}
+	a.applyTransport(rt)
+}
+
+// applyTransport 设置决策、屏幕分析和坐标识别客户端的传输
+func (a *PhoneAgent) applyTransport(rt http.RoundTripper) {
+	a.visionClient.SetTransport(rt)
+	a.coordClient.SetTransport(rt)
+	a.decisionModel.SetTransport(rt)
+}
+
+// recordStep 补全当前步骤的结果和总耗时并写入记录器
+func (a *PhoneAgent) recordStep(result *StepResult) {
+	step := a.current
+	step.Timings.Total = time.Since(step.StartedAt).Seconds()
+	if result != nil && step.Result == nil && !result.Success {
+		step.Error = result.Message
+	}
+	if result != nil && (result.Usage.Calls > 0 || result.Usage.CacheHits > 0) {
+		usage := result.Usage
+		step.Usage = &usage
+	}
+
+	if a.recorder == nil {
+		return
+	}
+	if err := a.recorder.RecordStep(step); err != nil && a.config.Verbose {
+		fmt.Printf("Trace error: %v\n", err)
+	}
+}
+
+// traceResult 转换动作执行结果为记录格式
+func traceResult(result *actions.ActionResult) *trace.Result {
+	if result == nil {
+		return nil
+	}
+	return &trace.Result{
+		Success:      result.Success,
+		ShouldFinish: result.ShouldFinish,
+		Message:      result.Message,
+	}
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-phone-agent/adb"
+)
+
+// NoVisibleChange 操作执行成功但屏幕没有可见变化时写入 ActionHistory 的备注
+const NoVisibleChange = "no visible change"
+
+// minVisibleChange 视为屏幕变化的最小像素变化比例
+const minVisibleChange = 0.002
+
+// verifiableActions 执行后需要校验屏幕变化的操作
+var verifiableActions = map[string]bool{
+	"Tap":       true,
+	"DoubleTap": true,
+	"LongPress": true,
+	"Swipe":     true,
+	"Type":      true,
+	"Back":      true,
+}
+
+// verifyAction 操作执行后重新截图并与执行前对比
+//
+// 返回执行后的截图（供下一步复用）和校验备注；无法校验时备注为空。
+func (a *PhoneAgent) verifyAction(ctx context.Context, action map[string]interface{}, before *adb.Screenshot) (*adb.Screenshot, string) {
+	actionType, _ := action["action"].(string)
+	if !verifiableActions[actionType] || before == nil || before.IsSensitive {
+		return nil, ""
+	}
+
+	if a.config.VerifyDelay > 0 {
+		timer := time.NewTimer(a.config.VerifyDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ""
+		case <-timer.C:
+		}
+	}
+
+	after, err := a.device.GetScreenshot(ctx, 10)
+	if err != nil {
+		if a.config.Verbose {
+			fmt.Printf("Verify screenshot error: %v\n", err)
+		}
+		return nil, ""
+	}
+	if after.IsSensitive {
+		return after, ""
+	}
+
+	diff, err := adb.CompareScreenshots(before, after)
+	if err != nil {
+		if a.config.Verbose {
+			fmt.Printf("Verify compare error: %v\n", err)
+		}
+		return after, ""
+	}
+
+	if diff.Changed(minVisibleChange) {
+		return after, ""
+	}
+
+	if a.config.Verbose {
+		fmt.Printf("⚠️ %s 执行后屏幕无明显变化\n", actionType)
+	}
+	return after, NoVisibleChange
+}
@@ -0,0 +1,140 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kind 请求类型
+type Kind string
+
+// 请求类型
+const (
+	KindConfirmation Kind = "confirmation" // 敏感操作确认，等待 approve/deny
+	KindTakeover     Kind = "takeover"     // 人工接管，人完成操作后 resume
+)
+
+// Decision 人工决定
+type Decision string
+
+// 人工决定
+const (
+	Approve Decision = "approve" // 允许执行敏感操作
+	Deny    Decision = "deny"    // 拒绝敏感操作，任务结束
+	Resume  Decision = "resume"  // 人工接管完成，继续执行
+)
+
+// ErrTimeout 等待人工决定超时
+var ErrTimeout = errors.New("approval timed out")
+
+// Request 发给人工的审批请求
+type Request struct {
+	ID        string    `json:"id"`
+	Kind      Kind      `json:"kind"`
+	Device    string    `json:"device"`
+	Message   string    `json:"message"`
+	Sensitive bool      `json:"sensitive"` // 命中敏感关键词（支付、转账等），超时一律拒绝
+	CreatedAt time.Time `json:"created_at"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// Approver 把请求交给人工并等待决定，ctx 超时返回 ErrTimeout
+type Approver interface {
+	Request(ctx context.Context, req Request) (Decision, error)
+}
+
+// Canceler 可以结束设备上等待中的请求，Queue 和 WebhookApprover 都实现了该接口
+type Canceler interface {
+	CancelDevice(device string)
+}
+
+// Policy 等待人工决定的策略
+type Policy struct {
+	Timeout           time.Duration // 等待上限
+	OnTimeout         Decision      // 确认请求超时后的默认决定：approve 或 deny
+	SensitiveKeywords []string      // 命中的确认请求超时后总是 deny，即使 OnTimeout 为 approve
+}
+
+// DefaultSensitiveKeywords 默认敏感关键词
+func DefaultSensitiveKeywords() []string {
+	return []string{"支付", "付款", "转账", "密码", "购买", "下单", "pay", "transfer", "password", "purchase"}
+}
+
+// DefaultPolicy 返回默认策略：等待 5 分钟，超时拒绝
+func DefaultPolicy() Policy {
+	return Policy{
+		Timeout:           5 * time.Minute,
+		OnTimeout:         Deny,
+		SensitiveKeywords: DefaultSensitiveKeywords(),
+	}
+}
+
+// IsSensitive 消息是否命中敏感关键词
+func (p Policy) IsSensitive(message string) bool {
+	lower := strings.ToLower(message)
+	for _, keyword := range p.SensitiveKeywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Callbacks 基于 Approver 创建 ActionHandler 的确认和接管回调
+//
+// 确认请求：收到 approve 才执行；deny、出错或超时按策略处理，命中敏感关键词时超时一律拒绝。
+// 接管请求：收到 resume 或超时后继续执行。
+// 任务取消时通过 Canceler.CancelDevice 结束等待，确认请求按拒绝处理。
+func Callbacks(approver Approver, policy Policy, device string) (func(string) bool, func(string)) {
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultPolicy().Timeout
+	}
+
+	ask := func(kind Kind, message string) (Decision, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), policy.Timeout)
+		defer cancel()
+
+		now := time.Now()
+		return approver.Request(ctx, Request{
+			Kind:      kind,
+			Device:    device,
+			Message:   message,
+			Sensitive: kind == KindConfirmation && policy.IsSensitive(message),
+			CreatedAt: now,
+			Deadline:  now.Add(policy.Timeout),
+		})
+	}
+
+	confirm := func(message string) bool {
+		decision, err := ask(KindConfirmation, message)
+		if err == nil {
+			return decision == Approve
+		}
+
+		fallback := policy.OnTimeout
+		if !errors.Is(err, ErrTimeout) || policy.IsSensitive(message) {
+			fallback = Deny
+		}
+		fmt.Printf("[%s] 确认请求未得到答复（%v），按策略 %s: %s\n", device, err, fallback, message)
+		return fallback == Approve
+	}
+
+	takeover := func(message string) {
+		if _, err := ask(KindTakeover, message); err != nil {
+			fmt.Printf("[%s] 接管请求未得到答复（%v），继续执行: %s\n", device, err, message)
+		}
+	}
+
+	return confirm, takeover
+}
+
+// waitErr 把 ctx 的超时错误转换为 ErrTimeout
+func waitErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,123 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNotFound 待审批请求不存在（已答复或已超时）
+var ErrNotFound = errors.New("approval request not found")
+
+// Queue 待审批队列，serve 模式下通过 API 查看和答复
+type Queue struct {
+	mu      sync.Mutex
+	seq     int
+	pending map[string]*pendingRequest
+	order   []string
+}
+
+// pendingRequest 等待答复的请求
+type pendingRequest struct {
+	req   Request
+	reply chan Decision
+}
+
+// NewQueue 创建待审批队列
+func NewQueue() *Queue {
+	return &Queue{pending: map[string]*pendingRequest{}}
+}
+
+// Request 把请求加入队列并等待 Resolve，ctx 结束时移出队列
+func (q *Queue) Request(ctx context.Context, req Request) (Decision, error) {
+	q.mu.Lock()
+	q.seq++
+	req.ID = fmt.Sprintf("approval-%d", q.seq)
+	p := &pendingRequest{req: req, reply: make(chan Decision, 1)}
+	q.pending[req.ID] = p
+	q.order = append(q.order, req.ID)
+	q.mu.Unlock()
+
+	defer q.remove(req.ID)
+
+	select {
+	case decision := <-p.reply:
+		return decision, nil
+	case <-ctx.Done():
+		return "", waitErr(ctx)
+	}
+}
+
+// Pending 返回等待答复的请求（按提交顺序）
+func (q *Queue) Pending() []Request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	requests := make([]Request, 0, len(q.order))
+	for _, id := range q.order {
+		requests = append(requests, q.pending[id].req)
+	}
+	return requests
+}
+
+// Resolve 答复请求
+func (q *Queue) Resolve(id string, decision Decision) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p, ok := q.pending[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, err := validDecision(p.req.Kind, decision); err != nil {
+		return err
+	}
+	q.resolveLocked(id, decision)
+	return nil
+}
+
+// CancelDevice 结束设备上所有等待中的请求（任务取消时调用）：确认请求拒绝，接管请求继续
+func (q *Queue) CancelDevice(device string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, id := range append([]string{}, q.order...) {
+		p := q.pending[id]
+		if p.req.Device != device {
+			continue
+		}
+		if p.req.Kind == KindTakeover {
+			q.resolveLocked(id, Resume)
+		} else {
+			q.resolveLocked(id, Deny)
+		}
+	}
+}
+
+// resolveLocked 发送答复并移出队列，调用方持有 q.mu
+func (q *Queue) resolveLocked(id string, decision Decision) {
+	q.pending[id].reply <- decision
+	q.removeLocked(id)
+}
+
+// remove 移出队列
+func (q *Queue) remove(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeLocked(id)
+}
+
+// removeLocked 移出队列，调用方持有 q.mu
+func (q *Queue) removeLocked(id string) {
+	if _, ok := q.pending[id]; !ok {
+		return
+	}
+	delete(q.pending, id)
+	for i, existing := range q.order {
+		if existing == id {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
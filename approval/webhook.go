@@ -0,0 +1,133 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebhookApprover 把审批请求 POST 到 webhook，webhook 在人工决定后返回 {"decision": "approve|deny|resume"}
+//
+// webhook 可以一直挂起请求直到有人决定，Approver 最多等待 Policy.Timeout；
+// 任务取消时调用 CancelDevice 提前结束该设备等待中的请求。
+type WebhookApprover struct {
+	URL    string
+	Token  string // 非空时发送 Authorization: Bearer <Token>
+	Client *http.Client
+
+	seq      int64
+	mu       sync.Mutex
+	inflight map[string]map[string]context.CancelFunc // 设备 -> 请求 ID -> 取消函数
+}
+
+// NewWebhookApprover 创建 webhook 审批
+func NewWebhookApprover(url, token string) *WebhookApprover {
+	return &WebhookApprover{URL: url, Token: token, Client: &http.Client{}}
+}
+
+// Request 发送请求并等待 webhook 返回决定
+func (w *WebhookApprover) Request(ctx context.Context, req Request) (Decision, error) {
+	if req.ID == "" {
+		req.ID = fmt.Sprintf("webhook-%d-%d", time.Now().Unix(), atomic.AddInt64(&w.seq, 1))
+	}
+	ctx, done := w.track(ctx, req)
+	defer done()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal approval request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create approval request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", waitErr(ctx)
+		}
+		return "", fmt.Errorf("failed to send approval request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", waitErr(ctx)
+		}
+		return "", fmt.Errorf("failed to read approval response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("approval webhook error: status=%d, body=%s", resp.StatusCode, data)
+	}
+
+	var result struct {
+		Decision Decision `json:"decision"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse approval response: %w", err)
+	}
+	return validDecision(req.Kind, result.Decision)
+}
+
+// CancelDevice 结束设备上所有等待中的请求（任务取消时调用），Request 返回 context.Canceled
+func (w *WebhookApprover) CancelDevice(device string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, cancel := range w.inflight[device] {
+		cancel()
+	}
+}
+
+// track 登记等待中的请求，返回可被 CancelDevice 取消的 ctx 和结束时的清理函数
+func (w *WebhookApprover) track(ctx context.Context, req Request) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	w.mu.Lock()
+	if w.inflight == nil {
+		w.inflight = make(map[string]map[string]context.CancelFunc)
+	}
+	if w.inflight[req.Device] == nil {
+		w.inflight[req.Device] = make(map[string]context.CancelFunc)
+	}
+	w.inflight[req.Device][req.ID] = cancel
+	w.mu.Unlock()
+
+	return ctx, func() {
+		w.mu.Lock()
+		delete(w.inflight[req.Device], req.ID)
+		if len(w.inflight[req.Device]) == 0 {
+			delete(w.inflight, req.Device)
+		}
+		w.mu.Unlock()
+		cancel()
+	}
+}
+
+// validDecision 校验决定是否适用于请求类型
+func validDecision(kind Kind, decision Decision) (Decision, error) {
+	switch {
+	case kind == KindConfirmation && (decision == Approve || decision == Deny):
+		return decision, nil
+	case kind == KindTakeover && decision == Resume:
+		return decision, nil
+	}
+	return "", fmt.Errorf("invalid decision %q for %s request", decision, kind)
+}
@@ -0,0 +1,53 @@
+package approval
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookCancelDevice(t *testing.T) {
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	// webhook 一直挂起，模拟无人答复
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	approver := NewWebhookApprover(server.URL, "")
+	policy := DefaultPolicy()
+	policy.OnTimeout = Approve
+	confirm, _ := Callbacks(approver, policy, "emulator-5554")
+
+	result := make(chan bool, 1)
+	go func() { result <- confirm("删除聊天记录") }()
+	<-received
+
+	// 其他设备的取消不影响等待中的请求
+	approver.CancelDevice("emulator-5556")
+	select {
+	case <-result:
+		t.Fatal("request for another device was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	approver.CancelDevice("emulator-5554")
+	select {
+	case approved := <-result:
+		if approved {
+			t.Error("cancelled confirmation approved, want deny")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("confirmation still waiting after CancelDevice")
+	}
+
+	approver.mu.Lock()
+	defer approver.mu.Unlock()
+	if len(approver.inflight) != 0 {
+		t.Errorf("inflight = %v, want empty after the request returned", approver.inflight)
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"go-phone-agent/adb"
+)
+
+// Options 缓存配置
+type Options struct {
+	TTL          time.Duration // 条目有效期，0 表示不过期
+	MaxEntries   int           // 最多保留的条目数，超出时淘汰最早写入的，0 表示不限
+	Dir          string        // 磁盘存储目录，为空时只在内存中缓存
+	Perceptual   bool          // 精确匹配失败时按截图感知指纹（忽略状态栏）查找相似画面
+	MaxDistance  int           // 感知匹配允许的最大 dHash 汉明距离（0-64）
+	MaxPixelDiff float64       // 感知匹配允许的最大变化像素比例（0-1）
+}
+
+// DefaultOptions 返回默认配置：内存缓存，10 分钟过期，最多 1000 条；
+// 感知匹配的阈值与操作校验判断“屏幕无变化”的标准相同
+func DefaultOptions() Options {
+	return Options{
+		TTL:          10 * time.Minute,
+		MaxEntries:   1000,
+		MaxDistance:  0,
+		MaxPixelDiff: 0.002,
+	}
+}
+
+// Key 一次视觉模型请求的缓存键
+type Key struct {
+	ID     string                 // 精确键：模型名称、提示词和截图内容的 SHA-256
+	Scope  string                 // 模型名称和提示词的 SHA-256，感知匹配只在同一 Scope 内查找
+	Screen *adb.ScreenFingerprint // 截图感知指纹，未启用感知匹配或计算失败时为 nil
+}
+
+// entry 缓存条目
+type entry struct {
+	ID        string                 `json:"id"`
+	Scope     string                 `json:"scope"`
+	Screen    *adb.ScreenFingerprint `json:"screen,omitempty"`
+	Value     string                 `json:"value"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Stats 命中统计
+type Stats struct {
+	Entries        int // 当前条目数
+	Hits           int // 精确命中次数
+	PerceptualHits int // 感知命中次数
+	Misses         int // 未命中次数
+}
+
+// Cache 按内容寻址的视觉模型响应缓存（屏幕描述、坐标），可在多个 agent 间共享
+//
+// 相同模型、相同提示词、相同截图的请求直接返回上次的响应；启用感知匹配后，
+// 状态栏时间变化等细微差异的画面也视为相同。配置了 Dir 时条目同时写入磁盘，重启后继续使用。
+type Cache struct {
+	opts    Options
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []string // 条目 ID，按写入时间排序，用于淘汰
+	stats   Stats
+}
+
+// New 创建缓存，配置了 Dir 时加载磁盘中未过期的条目
+func New(opts Options) (*Cache, error) {
+	c := &Cache{
+		opts:    opts,
+		entries: map[string]*entry{},
+	}
+	if opts.Dir != "" {
+		if err := c.load(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Key 计算请求的缓存键，启用感知匹配时解码截图计算感知指纹
+func (c *Cache) Key(modelName string, prompt string, screenshot *adb.Screenshot) Key {
+	scope := digest(modelName, prompt)
+	key := Key{
+		Scope: scope,
+		ID:    digest(scope, screenshot.Base64Data),
+	}
+	if c.opts.Perceptual {
+		if fingerprint, err := adb.Fingerprint(screenshot); err == nil {
+			key.Screen = fingerprint
+		}
+	}
+	return key
+}
+
+// Get 查找缓存的响应：先精确匹配，启用感知匹配时再查找同一 Scope 内最相似的画面
+func (c *Cache) Get(key Key) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := c.entries[key.ID]; ok && !c.expired(e, now) {
+		c.stats.Hits++
+		return e.Value, true
+	}
+
+	// 感知匹配：dHash 距离和变化像素比例都在阈值内，取变化最小的画面
+	if c.opts.Perceptual && key.Screen != nil {
+		var best *entry
+		bestDiff := 0.0
+		for _, e := range c.entries {
+			if e.Scope != key.Scope || e.Screen == nil || c.expired(e, now) {
+				continue
+			}
+			// 先用 dHash 过滤，再逐像素对比
+			if adb.HammingDistance(key.Screen.Hash, e.Screen.Hash) > c.opts.MaxDistance {
+				continue
+			}
+			diff := key.Screen.Diff(e.Screen)
+			if diff.PixelDiff > c.opts.MaxPixelDiff {
+				continue
+			}
+			if best == nil || diff.PixelDiff < bestDiff {
+				best, bestDiff = e, diff.PixelDiff
+			}
+		}
+		if best != nil {
+			c.stats.PerceptualHits++
+			return best.Value, true
+		}
+	}
+
+	c.stats.Misses++
+	return "", false
+}
+
+// Put 写入响应，配置了 Dir 时同时写入磁盘；磁盘写入失败时内存中的条目仍然有效
+func (c *Cache) Put(key Key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{
+		ID:        key.ID,
+		Scope:     key.Scope,
+		Screen:    key.Screen,
+		Value:     value,
+		CreatedAt: time.Now(),
+	}
+	if _, ok := c.entries[e.ID]; ok {
+		c.removeOrder(e.ID)
+	}
+	c.entries[e.ID] = e
+	c.order = append(c.order, e.ID)
+	c.evict()
+
+	if c.opts.Dir != "" {
+		return c.save(e)
+	}
+	return nil
+}
+
+// Stats 返回命中统计
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// expired 条目是否已过期
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	return c.opts.TTL > 0 && now.Sub(e.CreatedAt) > c.opts.TTL
+}
+
+// evict 删除过期条目，并在超出 MaxEntries 时淘汰最早写入的条目（调用方持有 c.mu）
+func (c *Cache) evict() {
+	now := time.Now()
+	for len(c.order) > 0 {
+		oldest := c.entries[c.order[0]]
+		if !c.expired(oldest, now) && (c.opts.MaxEntries <= 0 || len(c.order) <= c.opts.MaxEntries) {
+			return
+		}
+		c.order = c.order[1:]
+		delete(c.entries, oldest.ID)
+		if c.opts.Dir != "" {
+			c.remove(oldest.ID)
+		}
+	}
+}
+
+// removeOrder 从写入顺序中移除条目（调用方持有 c.mu）
+func (c *Cache) removeOrder(id string) {
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// digest 多个字符串的 SHA-256（以 0 字节分隔）
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
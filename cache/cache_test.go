@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+
+	"go-phone-agent/adb"
+)
+
+// 测试截图尺寸，顶部 4%（19 行）为状态栏
+const (
+	shotWidth  = 216
+	shotHeight = 480
+)
+
+// screenshot 生成从左到右变亮的渐变截图，rects 内的像素涂黑
+func screenshot(t *testing.T, reversed bool, rects ...image.Rectangle) *adb.Screenshot {
+	t.Helper()
+	img := imaging.New(shotWidth, shotHeight, color.White)
+	for y := 0; y < shotHeight; y++ {
+		for x := 0; x < shotWidth; x++ {
+			v := uint8(x * 255 / (shotWidth - 1))
+			if reversed {
+				v = 255 - v
+			}
+			for _, r := range rects {
+				if (image.Point{X: x, Y: y}).In(r) {
+					v = 0
+				}
+			}
+			img.Set(x, y, color.Gray{Y: v})
+		}
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.PNG); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return &adb.Screenshot{Base64Data: base64.StdEncoding.EncodeToString(buf.Bytes()), Width: shotWidth, Height: shotHeight, MimeType: "image/png"}
+}
+
+// newCache 创建缓存，创建失败时终止测试
+func newCache(t *testing.T, opts Options) *Cache {
+	t.Helper()
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// mustGet 断言命中并返回缓存值
+func mustGet(t *testing.T, c *Cache, key Key, want string) {
+	t.Helper()
+	if got, ok := c.Get(key); !ok || got != want {
+		t.Errorf("Get = %q, %v, want %q, true", got, ok, want)
+	}
+}
+
+// mustMiss 断言未命中
+func mustMiss(t *testing.T, c *Cache, key Key) {
+	t.Helper()
+	if got, ok := c.Get(key); ok {
+		t.Errorf("Get = %q, want miss", got)
+	}
+}
+
+func TestCacheExactHit(t *testing.T) {
+	c := newCache(t, DefaultOptions())
+	shot := screenshot(t, false)
+	if err := c.Put(c.Key("vision", "描述屏幕", shot), "首页"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// 相同内容的截图重新计算键后精确命中
+	mustGet(t, c, c.Key("vision", "描述屏幕", screenshot(t, false)), "首页")
+	mustMiss(t, c, c.Key("vision", "描述屏幕", screenshot(t, true)))
+
+	if stats := c.Stats(); stats != (Stats{Entries: 1, Hits: 1, Misses: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCachePerceptual(t *testing.T) {
+	statusBar := image.Rect(0, 0, shotWidth, 15)
+	smallBlock := image.Rect(100, 200, 120, 220)
+
+	tests := []struct {
+		name         string
+		maxDistance  int
+		maxPixelDiff float64
+		lookup       *adb.Screenshot
+		wantHit      bool
+	}{
+		{"status bar ignored", 0, 0.002, screenshot(t, false, statusBar), true},
+		{"small change above pixel threshold", 64, 0.002, screenshot(t, false, smallBlock), false},
+		{"small change within pixel threshold", 64, 0.01, screenshot(t, false, smallBlock), true},
+		{"hash distance above threshold", 0, 1, screenshot(t, true), false},
+		{"hash distance within threshold", 64, 1, screenshot(t, true), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Perceptual = true
+			opts.MaxDistance = tt.maxDistance
+			opts.MaxPixelDiff = tt.maxPixelDiff
+			c := newCache(t, opts)
+			c.Put(c.Key("vision", "描述屏幕", screenshot(t, false)), "首页")
+
+			key := c.Key("vision", "描述屏幕", tt.lookup)
+			if key.Screen == nil {
+				t.Fatal("perceptual key has no fingerprint")
+			}
+			if tt.wantHit {
+				mustGet(t, c, key, "首页")
+				if stats := c.Stats(); stats.PerceptualHits != 1 || stats.Hits != 0 {
+					t.Errorf("stats = %+v, want one perceptual hit", stats)
+				}
+			} else {
+				mustMiss(t, c, key)
+			}
+		})
+	}
+}
+
+func TestCacheScopeIsolation(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Perceptual = true
+	opts.MaxDistance = 64
+	opts.MaxPixelDiff = 1
+	c := newCache(t, opts)
+	shot := screenshot(t, false)
+	c.Put(c.Key("vision", "描述屏幕", shot), "首页")
+
+	// 同一截图换提示词或模型都不命中，感知匹配也不跨 Scope
+	mustMiss(t, c, c.Key("vision", "定位登录按钮", shot))
+	mustMiss(t, c, c.Key("vision-large", "描述屏幕", shot))
+	mustGet(t, c, c.Key("vision", "描述屏幕", shot), "首页")
+
+	c.Put(c.Key("vision", "定位登录按钮", shot), "[500,900]")
+	mustGet(t, c, c.Key("vision", "定位登录按钮", shot), "[500,900]")
+	mustGet(t, c, c.Key("vision", "描述屏幕", shot), "首页")
+}
+
+func TestCacheTTL(t *testing.T) {
+	opts := DefaultOptions()
+	opts.TTL = time.Minute
+	opts.Perceptual = true
+	c := newCache(t, opts)
+	key := c.Key("vision", "描述屏幕", screenshot(t, false))
+	c.Put(key, "首页")
+	mustGet(t, c, key, "首页")
+
+	c.entries[key.ID].CreatedAt = time.Now().Add(-2 * time.Minute)
+	mustMiss(t, c, key)
+	// 过期条目也不参与感知匹配
+	mustMiss(t, c, c.Key("vision", "描述屏幕", screenshot(t, false, image.Rect(0, 0, shotWidth, 15))))
+
+	// 下次写入时清理过期条目
+	c.Put(c.Key("vision", "描述屏幕", screenshot(t, true)), "设置页")
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Errorf("entries = %d after put, want the expired entry evicted", stats.Entries)
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxEntries = 2
+	opts.Dir = t.TempDir()
+	c := newCache(t, opts)
+	a := c.Key("vision", "a", screenshot(t, false))
+	b := c.Key("vision", "b", screenshot(t, false))
+	d := c.Key("vision", "d", screenshot(t, false))
+
+	c.Put(a, "A")
+	c.Put(b, "B")
+	// 重新写入 a 使其成为最新的条目，超出上限时淘汰 b
+	c.Put(a, "A2")
+	c.Put(d, "D")
+
+	mustGet(t, c, a, "A2")
+	mustMiss(t, c, b)
+	mustGet(t, c, d, "D")
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Errorf("entries = %d, want 2", stats.Entries)
+	}
+	if _, err := os.Stat(filepath.Join(opts.Dir, b.ID+entryExt)); !os.IsNotExist(err) {
+		t.Errorf("evicted entry still on disk: %v", err)
+	}
+}
+
+func TestCacheDiskRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.Dir = dir
+	opts.TTL = time.Minute
+	opts.Perceptual = true
+	c := newCache(t, opts)
+
+	fresh := c.Key("vision", "描述屏幕", screenshot(t, false))
+	stale := c.Key("vision", "描述屏幕", screenshot(t, true))
+	if err := c.Put(fresh, "首页"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := c.Put(stale, "设置页"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// 直接写入一个已过期的条目
+	c.entries[stale.ID].CreatedAt = time.Now().Add(-time.Hour)
+	if err := c.save(c.entries[stale.ID]); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt"+entryExt)
+	misnamed := filepath.Join(dir, "misnamed"+entryExt)
+	other := filepath.Join(dir, "README.txt")
+	os.WriteFile(corrupt, []byte("{not json"), 0644)
+	data, _ := os.ReadFile(filepath.Join(dir, fresh.ID+entryExt))
+	os.WriteFile(misnamed, data, 0644)
+	os.WriteFile(other, []byte("keep"), 0644)
+
+	reloaded := newCache(t, opts)
+	mustGet(t, reloaded, fresh, "首页")
+	// 感知指纹随条目保存，重启后仍可感知匹配
+	mustGet(t, reloaded, reloaded.Key("vision", "描述屏幕", screenshot(t, false, image.Rect(0, 0, shotWidth, 15))), "首页")
+	mustMiss(t, reloaded, stale)
+	if stats := reloaded.Stats(); stats.Entries != 1 {
+		t.Errorf("entries = %d after reload, want 1", stats.Entries)
+	}
+
+	for _, path := range []string{filepath.Join(dir, stale.ID+entryExt), corrupt, misnamed} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed on load: %v", filepath.Base(path), err)
+		}
+	}
+	for _, path := range []string{filepath.Join(dir, fresh.ID+entryExt), other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed on load: %v", filepath.Base(path), err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 磁盘上每个条目一个 JSON 文件：<Dir>/<ID>.json
+const entryExt = ".json"
+
+// load 加载磁盘中的条目，删除已过期和无法解析的文件
+func (c *Cache) load() error {
+	if err := os.MkdirAll(c.opts.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	files, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	now := time.Now()
+	var loaded []*entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), entryExt) {
+			continue
+		}
+		path := filepath.Join(c.opts.Dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil || e.ID+entryExt != file.Name() || c.expired(&e, now) {
+			os.Remove(path)
+			continue
+		}
+		loaded = append(loaded, &e)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].CreatedAt.Before(loaded[j].CreatedAt) })
+	for _, e := range loaded {
+		c.entries[e.ID] = e
+		c.order = append(c.order, e.ID)
+	}
+	c.evict()
+	return nil
+}
+
+// save 写入单个条目，先写临时文件再重命名，避免并发读取到不完整的文件
+func (c *Cache) save(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	path := filepath.Join(c.opts.Dir, e.ID+entryExt)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// remove 删除磁盘上的条目
+func (c *Cache) remove(id string) {
+	os.Remove(filepath.Join(c.opts.Dir, id+entryExt))
+}
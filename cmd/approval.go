@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go-phone-agent/approval"
+	"go-phone-agent/config"
+)
+
+// approvalPolicy 根据配置构建审批策略
+func approvalPolicy(cfg *config.Config) approval.Policy {
+	policy := approval.DefaultPolicy()
+	if cfg.Approval == nil {
+		return policy
+	}
+	if cfg.Approval.Timeout > 0 {
+		policy.Timeout = time.Duration(cfg.Approval.Timeout * float64(time.Second))
+	}
+	if cfg.Approval.OnTimeout != "" {
+		policy.OnTimeout = approval.Decision(cfg.Approval.OnTimeout)
+	}
+	if cfg.Approval.SensitiveKeywords != nil {
+		policy.SensitiveKeywords = cfg.Approval.SensitiveKeywords
+	}
+	return policy
+}
+
+// approvalMode 实际使用的审批方式，未配置时命令行用 terminal，serve 模式用 queue
+func approvalMode(cfg *config.Config, serve bool) string {
+	if cfg.Approval != nil && cfg.Approval.Mode != "" {
+		return cfg.Approval.Mode
+	}
+	if serve {
+		return "queue"
+	}
+	return "terminal"
+}
+
+// newApprover 按配置创建审批方式，terminal 模式返回 nil 使用终端交互
+//
+// queue 模式需要传入 serve 模式的待审批队列。同一个 Approver 在所有设备间共享，
+// 以便任务取消时结束对应设备等待中的请求。
+func newApprover(cfg *config.Config, queue *approval.Queue) (approval.Approver, error) {
+	switch mode := approvalMode(cfg, queue != nil); mode {
+	case "terminal":
+		return nil, nil
+	case "webhook":
+		return approval.NewWebhookApprover(cfg.Approval.WebhookURL, cfg.Approval.WebhookToken), nil
+	case "queue":
+		if queue == nil {
+			return nil, fmt.Errorf("approval mode queue is only available with --serve")
+		}
+		return queue, nil
+	default:
+		return nil, fmt.Errorf("unknown approval mode: %s", mode)
+	}
+}
+
+// approvalCallbacks 创建设备的确认和接管回调，approver 为 nil 时返回 nil 使用终端交互
+func approvalCallbacks(cfg *config.Config, approver approval.Approver, deviceID string) (func(string) bool, func(string)) {
+	if approver == nil {
+		return nil, nil
+	}
+	return approval.Callbacks(approver, approvalPolicy(cfg), deviceID)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/config"
+	"go-phone-agent/pool"
+)
+
+// runBatch 把任务文件中的任务分配到设备池并行执行，结束后输出汇总，返回是否全部成功
+//
+// 配置了 device-id 时只使用该设备，否则使用 pool.devices 或所有已连接的设备。
+func runBatch(ctx context.Context, cfg *config.Config, tasksFile string) (bool, error) {
+	tasks, err := readTasks(tasksFile)
+	if err != nil {
+		return false, err
+	}
+	if len(tasks) == 0 {
+		return false, fmt.Errorf("no tasks in %s", tasksFile)
+	}
+
+	opts := pool.DefaultOptions()
+	if cfg.Pool != nil {
+		opts.Devices = cfg.Pool.Devices
+		opts.HealthInterval = time.Duration(cfg.Pool.HealthInterval * float64(time.Second))
+		opts.MaxAttempts = cfg.Pool.MaxAttempts
+	}
+	if cfg.Agent.DeviceID != "" {
+		opts.Devices = []string{cfg.Agent.DeviceID}
+	}
+	decisionConfig := buildDecisionConfig(cfg)
+	opts.TraceDir = cfg.Agent.TraceDir
+	opts.TraceMeta = modelMeta(cfg, decisionConfig)
+
+	// 视觉缓存在所有设备间共享
+	sharedCache, err := visionCache(cfg)
+	if err != nil {
+		return false, err
+	}
+
+	approver, err := newApprover(cfg, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// 每台设备独立的 agent 与模型客户端
+	factory := func(deviceID string) (*agent.PhoneAgent, error) {
+		if !adb.CheckADBKeyboard(ctx, deviceID) {
+			fmt.Printf("Warning: ADB Keyboard is not installed on %s, Type actions will fail\n", deviceID)
+		}
+		confirm, takeover := approvalCallbacks(cfg, approver, deviceID)
+		agentConfig := buildAgentConfig(cfg)
+		agentConfig.DeviceID = deviceID
+		agentConfig.VisionCache = sharedCache
+		device := adb.NewADBDevice(deviceID)
+		return agent.NewPhoneAgentWithDevice(device, buildDecisionConfig(cfg), agentConfig, confirm, takeover), nil
+	}
+	devicePool := pool.New(factory, opts)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("=" + strings.Repeat("=", 48))
+	fmt.Printf("Phone Agent - Batch Mode (%d tasks)\n", len(tasks))
+	fmt.Printf("Decision Model: %s\n", decisionConfig.Decision.ModelName)
+	fmt.Printf("Vision Model: %s\n", decisionConfig.Vision.ModelName)
+	if cfg.Agent.TraceDir != "" {
+		fmt.Printf("Trace: %s\n", cfg.Agent.TraceDir)
+	}
+	fmt.Println("=" + strings.Repeat("=", 48))
+
+	start := time.Now()
+	results := devicePool.Run(ctx, tasks)
+	summary := pool.Summarize(results, time.Since(start))
+
+	printBatchResults(results, devicePool.Devices(), summary, currency(cfg))
+	if sharedCache != nil {
+		stats := sharedCache.Stats()
+		fmt.Printf("Vision cache: %d hits, %d perceptual hits, %d misses, %d entries\n", stats.Hits, stats.PerceptualHits, stats.Misses, stats.Entries)
+	}
+	return summary.Failed == 0, nil
+}
+
+// readTasks 读取任务文件，每行一个任务，忽略空行和 # 开头的注释
+func readTasks(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tasks file: %w", err)
+	}
+	defer file.Close()
+
+	var tasks []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tasks = append(tasks, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	return tasks, nil
+}
+
+// printBatchResults 输出每个任务的结果、每台设备的统计和模型用量合计
+func printBatchResults(results []pool.Result, devices []pool.DeviceStatus, summary pool.Summary, currency string) {
+	fmt.Println()
+	fmt.Println("=" + strings.Repeat("=", 48))
+	fmt.Println("Results")
+	fmt.Println("=" + strings.Repeat("=", 48))
+	for _, result := range results {
+		status := "✓"
+		if !result.Success {
+			status = "✗"
+		}
+		device := result.Device
+		if device == "" {
+			device = "-"
+		}
+		fmt.Printf("%s #%d [%s] %s (%d steps, %.1fs", status, result.Index+1, device, result.Task, result.Steps, result.Duration.Seconds())
+		if result.Attempts > 1 {
+			fmt.Printf(", %d attempts", result.Attempts)
+		}
+		if result.Usage.Calls > 0 {
+			fmt.Printf(", %d tokens, cost %.4f", result.Usage.Total(), result.Usage.Cost)
+		}
+		fmt.Printf(")\n    %s\n", result.Result)
+		if result.TraceDir != "" {
+			fmt.Printf("    Trace: %s\n", result.TraceDir)
+		}
+	}
+
+	fmt.Println("-" + strings.Repeat("-", 48))
+	for _, device := range devices {
+		line := fmt.Sprintf("%s: %s, %d succeeded, %d failed", device.ID, device.State, device.Completed, device.Failed)
+		if device.LastError != "" {
+			line += " (" + device.LastError + ")"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println("-" + strings.Repeat("-", 48))
+	fmt.Printf("Total: %d, Succeeded: %d, Failed: %d, Time: %.1fs\n", summary.Total, summary.Succeeded, summary.Failed, summary.Duration.Seconds())
+	printUsage(summary.Usage, currency)
+}
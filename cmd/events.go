@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-phone-agent/agent"
+	"go-phone-agent/config"
+	"go-phone-agent/model"
+)
+
+// printEvents 返回把 agent 事件输出到终端的处理函数，verbose 为 false 时不输出
+func printEvents(verbose bool) func(agent.Event) {
+	return func(event agent.Event) {
+		if !verbose {
+			return
+		}
+
+		switch event.Type {
+		case agent.EventStepStarted:
+			fmt.Printf("\n%s 步骤 %d %s\n", strings.Repeat("-", 20), event.Step, strings.Repeat("-", 20))
+
+		case agent.EventScreenAnalyzed:
+			fmt.Println()
+			fmt.Println("📤 视觉模型 → 决策模型 (屏幕描述):")
+			fmt.Printf("%s\n", event.Description)
+			fmt.Println()
+
+		case agent.EventPlanProduced:
+			plan := event.Plan
+			fmt.Println("操作指令:")
+			fmt.Printf("操作类型: %s\n", plan.ActionType)
+			fmt.Printf("操作原因: %s\n", plan.Reason)
+			if len(plan.Parameters) > 0 {
+				params, _ := json.MarshalIndent(plan.Parameters, "  ", "")
+				fmt.Printf("操作参数: %s\n", string(params))
+			}
+			fmt.Println()
+
+		case agent.EventCoordinatesResolved:
+			fmt.Printf("📍 坐标: %v\n", event.Coordinates)
+
+		case agent.EventActionExecuted:
+			status := "✓"
+			if !event.Success {
+				status = "✗"
+			}
+			line := fmt.Sprintf("%s %v", status, event.Action["action"])
+			if event.Action["action"] == nil {
+				line = fmt.Sprintf("%s %v", status, event.Action["_metadata"])
+			}
+			if event.Message != "" {
+				line += ": " + event.Message
+			}
+			if event.Note != "" {
+				line += " (" + event.Note + ")"
+			}
+			fmt.Println(line)
+
+		case agent.EventFinished:
+			if event.Success {
+				message := event.Message
+				if message == "" {
+					message = "Done"
+				}
+				fmt.Printf("✅ 任务完成: %s\n", message)
+			} else {
+				fmt.Printf("❌ 任务中止: %s\n", event.Message)
+			}
+		}
+	}
+}
+
+// currency 计费币种，未配置时为空
+func currency(cfg *config.Config) string {
+	if cfg.Pricing == nil {
+		return ""
+	}
+	return cfg.Pricing.Currency
+}
+
+// formatUsage 用量的一行摘要：token 数、调用次数和费用
+func formatUsage(stats model.UsageStats, currency string) string {
+	line := fmt.Sprintf("%d tokens (prompt %d, completion %d", stats.Total(), stats.PromptTokens, stats.CompletionTokens)
+	if stats.ImageTokens > 0 {
+		line += fmt.Sprintf(", image %d", stats.ImageTokens)
+	}
+	line += fmt.Sprintf("), %d calls", stats.Calls)
+	if stats.CacheHits > 0 {
+		line += fmt.Sprintf(", %d cache hits", stats.CacheHits)
+	}
+	line += fmt.Sprintf(", cost %.4f", stats.Cost)
+	if currency != "" {
+		line += " " + currency
+	}
+	return line
+}
+
+// printUsage 输出任务的模型用量合计及各角色明细，没有模型调用时不输出
+func printUsage(usage model.UsageSummary, currency string) {
+	if usage.Calls == 0 && usage.CacheHits == 0 {
+		return
+	}
+	fmt.Printf("Usage: %s\n", formatUsage(usage.UsageStats, currency))
+	for _, role := range usage.RoleNames() {
+		stats := usage.Roles[role]
+		line := formatUsage(stats, currency)
+		if len(stats.Models) > 0 {
+			// 实际应答的模型，发生故障转移时包含备用模型
+			var models []string
+			for _, name := range stats.ModelNames() {
+				models = append(models, fmt.Sprintf("%s×%d", name, stats.Models[name]))
+			}
+			line += " [" + strings.Join(models, ", ") + "]"
+		}
+		fmt.Printf("  %s: %s\n", role, line)
+	}
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"go-phone-agent/adb"
 	"go-phone-agent/agent"
+	"go-phone-agent/cache"
 	"go-phone-agent/config"
 	"go-phone-agent/model"
+	"go-phone-agent/trace"
 )
 
 func main() {
@@ -23,6 +29,13 @@ func main() {
 	listDevices := flag.Bool("list-devices", false, "List connected devices and exit")
 	connect := flag.String("connect", "", "Connect to remote device (e.g., 192.168.1.100:5555)")
 	disconnect := flag.String("disconnect", "", "Disconnect from remote device")
+	traceDir := flag.String("trace", "", "Record every step of each run into a trace directory under this path")
+	replayDir := flag.String("replay", "", "Replay a recorded trace directory and exit")
+	serve := flag.Bool("serve", false, "Run as an HTTP API server that accepts tasks for every connected device")
+	tasksFile := flag.String("tasks", "", "Run every task in this file (one per line) in parallel across all connected devices and exit")
+	listen := flag.String("listen", "", "Listen address for -serve (overrides config, default 127.0.0.1:8080)")
+	reportDir := flag.String("report", "", "Generate an HTML report for a recorded trace directory and exit")
+	replayMode := flag.String("replay-mode", "actions", "Replay mode: actions (re-run recorded actions on the device) or model (re-run the agent with recorded model responses)")
 	// 决策模型模式参数（双模型架构）
 	decisionURL := flag.String("decision-url", "", "Decision model API base URL (overrides config)")
 	decisionKey := flag.String("decision-key", "", "Decision model API key (overrides config)")
@@ -42,20 +55,22 @@ func main() {
 
 	// 合并命令行参数（命令行参数优先级更高）
 	flags := &config.Flags{
-		MaxSteps:       *maxSteps,
-		DeviceID:       *deviceID,
-		Quiet:          *quiet,
-		LogEnabled:     *logEnabled,
-		ListDevices:    *listDevices,
-		Connect:        *connect,
-		Disconnect:     *disconnect,
-		DecisionURL:    *decisionURL,
-		DecisionKey:    *decisionKey,
-		DecisionModel:  *decisionModel,
-		VisionURL:      *visionURL,
-		VisionKey:      *visionKey,
-		VisionModel:    *visionModel,
-		ConfigFile:     *configFile,
+		MaxSteps:      *maxSteps,
+		DeviceID:      *deviceID,
+		Quiet:         *quiet,
+		LogEnabled:    *logEnabled,
+		ListDevices:   *listDevices,
+		Connect:       *connect,
+		Disconnect:    *disconnect,
+		DecisionURL:   *decisionURL,
+		DecisionKey:   *decisionKey,
+		DecisionModel: *decisionModel,
+		VisionURL:     *visionURL,
+		VisionKey:     *visionKey,
+		VisionModel:   *visionModel,
+		ConfigFile:    *configFile,
+		TraceDir:      *traceDir,
+		Listen:        *listen,
 	}
 	cfg.MergeWithFlags(flags)
 
@@ -82,9 +97,16 @@ func main() {
 	// 	return
 	// }
 
+	ctx := context.Background()
+
+	// adb 命令默认超时
+	if cfg.Agent.CommandTimeout > 0 {
+		adb.SetCommandTimeout(time.Duration(cfg.Agent.CommandTimeout) * time.Second)
+	}
+
 	// 列出设备
 	if flags.ListDevices {
-		devices, err := adb.ListDevices()
+		devices, err := adb.ListDevices(ctx)
 		if err != nil {
 			fmt.Printf("Failed to list devices: %v\n", err)
 			os.Exit(1)
@@ -109,7 +131,7 @@ func main() {
 	// 连接远程设备
 	if flags.Connect != "" {
 		fmt.Printf("Connecting to %s...\n", flags.Connect)
-		if err := adb.ConnectDevice(flags.Connect); err != nil {
+		if err := adb.ConnectDevice(ctx, flags.Connect); err != nil {
 			fmt.Printf("✗ Failed to connect: %v\n", err)
 			os.Exit(1)
 		}
@@ -120,7 +142,7 @@ func main() {
 	// 断开设备
 	if flags.Disconnect != "" {
 		fmt.Printf("Disconnecting from %s...\n", flags.Disconnect)
-		if err := adb.DisconnectDevice(flags.Disconnect); err != nil {
+		if err := adb.DisconnectDevice(ctx, flags.Disconnect); err != nil {
 			fmt.Printf("✗ Failed to disconnect: %v\n", err)
 			os.Exit(1)
 		}
@@ -128,8 +150,50 @@ func main() {
 		return
 	}
 
+	// 生成运行报告
+	if *reportDir != "" {
+		path, err := trace.GenerateReport(*reportDir)
+		if err != nil {
+			fmt.Printf("❌ Failed to generate report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Report saved to %s\n", path)
+		return
+	}
+
+	// 回放记录的运行
+	if *replayDir != "" {
+		if err := runReplay(ctx, cfg, *replayDir, *replayMode); err != nil {
+			fmt.Printf("❌ Replay failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// API 服务模式
+	if *serve {
+		if err := runServe(ctx, cfg); err != nil {
+			fmt.Printf("❌ Server failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 多设备并行执行任务队列
+	if *tasksFile != "" {
+		allSucceeded, err := runBatch(ctx, cfg, *tasksFile)
+		if err != nil {
+			fmt.Printf("❌ Batch failed: %v\n", err)
+			os.Exit(1)
+		}
+		if !allSucceeded {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 检查 ADB 连接
-	devices, err := adb.ListDevices()
+	devices, err := adb.ListDevices(ctx)
 	if err != nil {
 		fmt.Printf("❌ Failed to check ADB connection: %v\n", err)
 		os.Exit(1)
@@ -146,7 +210,7 @@ func main() {
 	}
 
 	// 检查 ADB Keyboard
-	if !adb.CheckADBKeyboard(cfg.Agent.DeviceID) {
+	if !adb.CheckADBKeyboard(ctx, cfg.Agent.DeviceID) {
 		fmt.Println("❌ ADB Keyboard is not installed on the device.")
 		fmt.Println("Solution:")
 		fmt.Println("  1. Download ADB Keyboard APK from:")
@@ -157,35 +221,21 @@ func main() {
 	}
 
 	// 将 config.Config 转换为 agent.AgentConfig 和 model.DecisionConfig
-	agentConfig := &agent.AgentConfig{
-		MaxSteps: cfg.Agent.MaxSteps,
-		DeviceID: cfg.Agent.DeviceID,
-		SystemPrompt: cfg.Agent.SystemPrompt,
-		Verbose: cfg.Agent.Verbose,
+	agentConfig := buildAgentConfig(cfg)
+	decisionConfig := buildDecisionConfig(cfg)
+	if agentConfig.VisionCache, err = visionCache(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	decisionConfig := &model.DecisionConfig{
-		Decision: &model.ModelConfig{
-			BaseURL:          cfg.Decision.Decision.BaseURL,
-			APIKey:           cfg.Decision.Decision.APIKey,
-			ModelName:        cfg.Decision.Decision.ModelName,
-			MaxTokens:        cfg.Decision.Decision.MaxTokens,
-			Temperature:      cfg.Decision.Decision.Temperature,
-			TopP:             cfg.Decision.Decision.TopP,
-			FrequencyPenalty: cfg.Decision.Decision.FrequencyPenalty,
-		},
-		Vision: &model.ModelConfig{
-			BaseURL:          cfg.Decision.Vision.BaseURL,
-			APIKey:           cfg.Decision.Vision.APIKey,
-			ModelName:        cfg.Decision.Vision.ModelName,
-			MaxTokens:        cfg.Decision.Vision.MaxTokens,
-			Temperature:      cfg.Decision.Vision.Temperature,
-			TopP:             cfg.Decision.Vision.TopP,
-			FrequencyPenalty: cfg.Decision.Vision.FrequencyPenalty,
-		},
-	}
-
-	phoneAgent := agent.NewPhoneAgentWithDecisionModel(decisionConfig, agentConfig, nil, nil)
+	approver, err := newApprover(cfg, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	confirm, takeover := approvalCallbacks(cfg, approver, cfg.Agent.DeviceID)
+	phoneAgent := agent.NewPhoneAgentWithDecisionModel(decisionConfig, agentConfig, confirm, takeover)
+	phoneAgent.Events().Subscribe(printEvents(agentConfig.Verbose))
 
 	// 打印配置信息
 	fmt.Println("=" + strings.Repeat("=", 48))
@@ -200,10 +250,19 @@ func main() {
 	fmt.Printf("Decision URL: %s\n", decisionConfig.Decision.BaseURL)
 	fmt.Printf("Vision Model: %s\n", decisionConfig.Vision.ModelName)
 	fmt.Printf("Vision URL: %s\n", decisionConfig.Vision.BaseURL)
+	if decisionConfig.Coordinate != nil {
+		fmt.Printf("Coordinate Model: %s\n", decisionConfig.Coordinate.ModelName)
+	}
 	fmt.Printf("Max Steps: %d\n", agentConfig.MaxSteps)
 	fmt.Printf("Device: %s\n", agentConfig.DeviceID)
+	if cfg.Agent.TraceDir != "" {
+		fmt.Printf("Trace: %s\n", cfg.Agent.TraceDir)
+	}
 	fmt.Println("=" + strings.Repeat("=", 48))
 
+	traceMeta := modelMeta(cfg, decisionConfig)
+	traceMeta.Device = agentConfig.DeviceID
+
 	// 获取任务
 	task := ""
 	args := flag.Args()
@@ -231,15 +290,177 @@ func main() {
 			}
 
 			fmt.Println()
-			result := phoneAgent.Run(input)
-			fmt.Printf("\nResult: %s\n\n", result)
+			result := runTask(ctx, phoneAgent, input, cfg.Agent.TraceDir, traceMeta)
+			fmt.Printf("\nResult: %s\n", result)
+			printUsage(phoneAgent.Usage(), currency(cfg))
+			fmt.Println()
 
 			phoneAgent.Reset()
 		}
 	} else {
 		// 单次任务模式
 		fmt.Printf("\nTask: %s\n\n", task)
-		result := phoneAgent.Run(task)
+		result := runTask(ctx, phoneAgent, task, cfg.Agent.TraceDir, traceMeta)
 		fmt.Printf("\nResult: %s\n", result)
+		printUsage(phoneAgent.Usage(), currency(cfg))
+	}
+}
+
+// buildAgentConfig 将配置文件中的 agent 配置转换为 agent.AgentConfig
+func buildAgentConfig(cfg *config.Config) *agent.AgentConfig {
+	agentConfig := &agent.AgentConfig{
+		MaxSteps:           cfg.Agent.MaxSteps,
+		DeviceID:           cfg.Agent.DeviceID,
+		SystemPrompt:       cfg.Agent.SystemPrompt,
+		Verbose:            cfg.Agent.Verbose,
+		ScreenSource:       cfg.Agent.ScreenSource,
+		VerifyActions:      cfg.Agent.VerifyActions,
+		VerifyDelay:        time.Duration(cfg.Agent.VerifyDelay * float64(time.Second)),
+		LoopDetection:      cfg.Agent.LoopDetection,
+		LoopWindow:         cfg.Agent.LoopWindow,
+		LoopThreshold:      cfg.Agent.LoopThreshold,
+		RecoveryStrategies: cfg.Agent.RecoveryStrategies,
+		SensitiveKeywords:  approvalPolicy(cfg).SensitiveKeywords,
+	}
+	if cfg.Screenshot != nil {
+		agentConfig.Screenshot = adb.ScreenshotOptions{
+			MaxLongEdge: cfg.Screenshot.MaxLongEdge,
+			JPEGQuality: cfg.Screenshot.JPEGQuality,
+			Grayscale:   cfg.Screenshot.Grayscale,
+		}
+	}
+	return agentConfig
+}
+
+// visionCache 按配置创建视觉模型响应缓存，未启用时返回 nil
+func visionCache(cfg *config.Config) (*cache.Cache, error) {
+	if cfg.VisionCache == nil || !cfg.VisionCache.Enabled {
+		return nil, nil
+	}
+	opts := cache.DefaultOptions()
+	opts.TTL = time.Duration(cfg.VisionCache.TTL * float64(time.Second))
+	opts.MaxEntries = cfg.VisionCache.MaxEntries
+	opts.Dir = cfg.VisionCache.Dir
+	opts.Perceptual = cfg.VisionCache.Perceptual
+	opts.MaxDistance = cfg.VisionCache.MaxDistance
+	opts.MaxPixelDiff = cfg.VisionCache.MaxPixelDiff
+	return cache.New(opts)
+}
+
+// buildDecisionConfig 将配置文件中的模型配置转换为 model.DecisionConfig
+func buildDecisionConfig(cfg *config.Config) *model.DecisionConfig {
+	decisionConfig := &model.DecisionConfig{
+		Decision: buildModelConfig(cfg.Decision.Decision, cfg.Pricing),
+		Vision:   buildModelConfig(cfg.Decision.Vision, cfg.Pricing),
+	}
+	if cfg.Decision.Coordinate != nil {
+		decisionConfig.Coordinate = buildModelConfig(cfg.Decision.Coordinate, cfg.Pricing)
+	}
+	return decisionConfig
+}
+
+// buildModelConfig 将单个角色的模型配置（含备用模型）转换为 model.ModelConfig，单价按模型名称从 pricing 中查找
+func buildModelConfig(m *config.ModelConfig, pricing *config.PricingConfig) *model.ModelConfig {
+	modelConfig := &model.ModelConfig{
+		Provider:         m.Provider,
+		BaseURL:          m.BaseURL,
+		APIKey:           m.APIKey,
+		ModelName:        m.ModelName,
+		MaxTokens:        m.MaxTokens,
+		Temperature:      m.Temperature,
+		TopP:             m.TopP,
+		FrequencyPenalty: m.FrequencyPenalty,
+		Timeout:          m.Timeout,
+		Retry:            retryPolicy(m.Retry),
+		OutputMode:       m.OutputMode,
+		MemoryBudget:     m.MemoryBudget,
+		DisableStream:    m.Stream != nil && !*m.Stream,
+		Price:            modelPrice(pricing, m.ModelName),
+		Breaker:          breakerPolicy(m.CircuitBreaker),
+	}
+	for _, fallback := range m.FallbackConfigs() {
+		modelConfig.Fallbacks = append(modelConfig.Fallbacks, buildModelConfig(fallback, pricing))
+	}
+	return modelConfig
+}
+
+// modelPrice 查找模型单价，未配置时返回 nil
+func modelPrice(pricing *config.PricingConfig, modelName string) *model.Price {
+	if pricing == nil {
+		return nil
+	}
+	price, ok := pricing.Models[modelName]
+	if !ok {
+		return nil
+	}
+	return &model.Price{Input: price.Input, Output: price.Output, Image: price.Image}
+}
+
+// modelMeta 运行记录中的模型信息和计费币种
+func modelMeta(cfg *config.Config, decisionConfig *model.DecisionConfig) trace.Meta {
+	meta := trace.Meta{
+		DecisionModel: decisionConfig.Decision.ModelName,
+		VisionModel:   decisionConfig.Vision.ModelName,
+		Currency:      currency(cfg),
+	}
+	if decisionConfig.Coordinate != nil {
+		meta.CoordinateModel = decisionConfig.Coordinate.ModelName
+	}
+	return meta
+}
+
+// retryPolicy 将配置文件中的重试配置转换为 model.RetryPolicy
+func retryPolicy(cfg *config.RetryConfig) model.RetryPolicy {
+	if cfg == nil {
+		return model.DefaultRetryPolicy()
+	}
+	return model.RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: time.Duration(cfg.InitialBackoff * float64(time.Second)),
+		MaxBackoff:     time.Duration(cfg.MaxBackoff * float64(time.Second)),
+		Multiplier:     cfg.Multiplier,
+		Jitter:         cfg.Jitter,
+	}
+}
+
+// breakerPolicy 将配置文件中的熔断配置转换为 model.BreakerPolicy
+func breakerPolicy(cfg *config.BreakerConfig) model.BreakerPolicy {
+	if cfg == nil {
+		return model.DefaultBreakerPolicy()
+	}
+	return model.BreakerPolicy{
+		FailureThreshold: cfg.FailureThreshold,
+		Cooldown:         time.Duration(cfg.Cooldown * float64(time.Second)),
+	}
+}
+
+// runTask 执行单个任务，Ctrl-C 只取消当前任务；traceDir 不为空时记录本次运行
+func runTask(ctx context.Context, phoneAgent *agent.PhoneAgent, task string, traceDir string, meta trace.Meta) string {
+	taskCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if traceDir == "" {
+		return phoneAgent.Run(taskCtx, task)
+	}
+
+	meta.Task = task
+	recorder, err := trace.NewRecorder(traceDir, meta)
+	if err != nil {
+		fmt.Printf("Warning: Failed to start trace: %v\n", err)
+		return phoneAgent.Run(taskCtx, task)
+	}
+	phoneAgent.SetRecorder(recorder)
+	defer phoneAgent.SetRecorder(nil)
+
+	result := phoneAgent.Run(taskCtx, task)
+	if err := recorder.Close(result); err != nil {
+		fmt.Printf("Warning: Failed to finish trace: %v\n", err)
+	}
+	fmt.Printf("Trace saved to %s\n", recorder.Dir())
+	if path, err := trace.GenerateReport(recorder.Dir()); err != nil {
+		fmt.Printf("Warning: Failed to generate report: %v\n", err)
+	} else {
+		fmt.Printf("Report saved to %s\n", path)
 	}
+	return result
 }
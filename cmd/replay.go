@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/config"
+	"go-phone-agent/trace"
+)
+
+// runReplay 回放记录目录
+//
+// actions 模式不调用模型，直接在设备上按顺序重新执行记录的动作；
+// model 模式使用假设备返回记录的截图、从磁盘返回记录的模型响应，重新运行 agent 逻辑，
+// 用于离线回归测试解析和决策流程。
+func runReplay(ctx context.Context, cfg *config.Config, dir string, mode string) error {
+	run, err := trace.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("=" + strings.Repeat("=", 48))
+	fmt.Printf("Replay: %s (%s)\n", run.Dir, mode)
+	fmt.Printf("Task: %s\n", run.Meta.Task)
+	fmt.Printf("Steps: %d, Model calls: %d\n", len(run.Steps), len(run.ModelCalls))
+	fmt.Println("=" + strings.Repeat("=", 48))
+
+	switch mode {
+	case "actions":
+		deviceID := cfg.Agent.DeviceID
+		if deviceID == "" {
+			devices, err := adb.ListDevices(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list devices: %w", err)
+			}
+			if len(devices) == 0 {
+				return fmt.Errorf("no devices connected")
+			}
+			deviceID = devices[0]
+		}
+
+		opts := trace.ReplayOptions{Delay: time.Second, Verbose: cfg.Agent.Verbose}
+		if err := trace.ReplayActions(ctx, run, adb.NewADBDevice(deviceID), opts); err != nil {
+			return err
+		}
+		fmt.Println("✅ Replay finished")
+		return nil
+
+	case "model":
+		device, err := run.FakeDevice()
+		if err != nil {
+			return err
+		}
+
+		agentConfig := buildAgentConfig(cfg)
+		agentConfig.DeviceID = device.ID()
+		agentConfig.VerifyDelay = 0
+		decisionConfig := buildDecisionConfig(cfg)
+		// 回放会校验请求的模型名，使用记录中的模型
+		if run.Meta.DecisionModel != "" {
+			decisionConfig.Decision.ModelName = run.Meta.DecisionModel
+		}
+		if run.Meta.VisionModel != "" {
+			decisionConfig.Vision.ModelName = run.Meta.VisionModel
+		}
+		if run.Meta.CoordinateModel != "" && decisionConfig.Coordinate != nil {
+			decisionConfig.Coordinate.ModelName = run.Meta.CoordinateModel
+		}
+
+		phoneAgent := agent.NewPhoneAgentWithDevice(device, decisionConfig, agentConfig,
+			func(string) bool { return true },
+			func(string) {},
+		)
+		phoneAgent.Events().Subscribe(printEvents(agentConfig.Verbose))
+		transport := trace.NewReplayTransport(run)
+		phoneAgent.SetModelTransport(transport)
+
+		result := phoneAgent.Run(ctx, run.Meta.Task)
+		fmt.Printf("\nResult: %s\n", result)
+		fmt.Printf("Recorded result: %s\n", run.Meta.Result)
+		printUsage(phoneAgent.Usage(), run.Meta.Currency)
+		fmt.Printf("Device calls: %s\n", strings.Join(device.Calls, " "))
+
+		if remaining := transport.Remaining(); remaining > 0 {
+			return fmt.Errorf("replay diverged: %d recorded model responses unused", remaining)
+		}
+		if result != run.Meta.Result {
+			return fmt.Errorf("replay diverged: result differs from recording")
+		}
+		fmt.Println("✅ Replay matches recording")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown replay mode: %s (expected actions or model)", mode)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/approval"
+	"go-phone-agent/config"
+	"go-phone-agent/server"
+)
+
+// runServe 以 HTTP API 服务运行，每台设备一个 agent 顺序执行提交的任务
+//
+// 配置了 device-id 时只使用该设备，否则使用所有已连接的设备。
+func runServe(ctx context.Context, cfg *config.Config) error {
+	devices, err := adb.ListDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+	if cfg.Agent.DeviceID != "" {
+		devices = []string{cfg.Agent.DeviceID}
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("no devices connected")
+	}
+
+	// 默认的 queue 模式通过 /api/approvals 答复确认和接管请求
+	var approvals *approval.Queue
+	if approvalMode(cfg, true) == "queue" {
+		approvals = approval.NewQueue()
+	}
+	approver, err := newApprover(cfg, approvals)
+	if err != nil {
+		return err
+	}
+	// 任务取消时结束设备上等待中的审批请求，webhook 模式同样适用
+	canceler, _ := approver.(approval.Canceler)
+
+	decisionConfig := buildDecisionConfig(cfg)
+	srv := server.New(server.Options{
+		Addr:      cfg.Server.Addr,
+		Token:     cfg.Server.Token,
+		MaxSteps:  cfg.Agent.MaxSteps,
+		TraceDir:  cfg.Agent.TraceDir,
+		TraceMeta: modelMeta(cfg, decisionConfig),
+		Approvals: approvals,
+		Canceler:  canceler,
+	})
+
+	// 视觉缓存在所有设备间共享
+	sharedCache, err := visionCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, deviceID := range devices {
+		if !adb.CheckADBKeyboard(ctx, deviceID) {
+			fmt.Printf("Warning: ADB Keyboard is not installed on %s, Type actions will fail\n", deviceID)
+		}
+		agentConfig := buildAgentConfig(cfg)
+		agentConfig.DeviceID = deviceID
+		agentConfig.VisionCache = sharedCache
+		confirm, takeover := approvalCallbacks(cfg, approver, deviceID)
+		device := adb.NewADBDevice(deviceID)
+		srv.AddDevice(device, agent.NewPhoneAgentWithDevice(device, buildDecisionConfig(cfg), agentConfig, confirm, takeover))
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("=" + strings.Repeat("=", 48))
+	fmt.Printf("Phone Agent API listening on %s\n", cfg.Server.Addr)
+	fmt.Printf("Devices: %s\n", strings.Join(srv.Devices(), ", "))
+	fmt.Printf("Approval: %s\n", approvalMode(cfg, true))
+	if cfg.Server.Token == "" {
+		fmt.Println("Warning: no API token configured, the API is open to anyone who can reach it")
+	}
+	fmt.Println("=" + strings.Repeat("=", 48))
+
+	return srv.ListenAndServe(ctx)
+}
@@ -11,46 +11,216 @@ import (
 
 // AgentConfig Agent 配置结构（从 agent 包移过来，避免循环导入）
 type AgentConfig struct {
-	MaxSteps     int    `yaml:"max-steps"`
-	DeviceID     string `yaml:"device-id"`
-	SystemPrompt string `yaml:"system-prompt"`
-	Verbose      bool   `yaml:"verbose"`
+	MaxSteps       int     `yaml:"max-steps"`
+	DeviceID       string  `yaml:"device-id"`
+	SystemPrompt   string  `yaml:"system-prompt"`
+	Verbose        bool    `yaml:"verbose"`
+	ScreenSource   string  `yaml:"screen-source"`
+	CommandTimeout int     `yaml:"command-timeout"` // 单条 adb 命令超时（秒）
+	VerifyActions  bool    `yaml:"verify-actions"`  // 操作后对比前后截图检测无效操作
+	VerifyDelay    float64 `yaml:"verify-delay"`    // 校验前等待界面稳定（秒）
+
+	LoopDetection      bool     `yaml:"loop-detection"`      // 检测重复操作并自动恢复
+	LoopWindow         int      `yaml:"loop-window"`         // 循环检测窗口（步数）
+	LoopThreshold      int      `yaml:"loop-threshold"`      // 同一屏幕同一操作重复次数阈值
+	RecoveryStrategies []string `yaml:"recovery-strategies"` // 恢复策略顺序
+
+	TraceDir string `yaml:"trace-dir"` // 运行记录目录，为空则不记录
 }
 
 // ModelConfig AI 模型配置（从 model 包移过来，避免循环导入）
 type ModelConfig struct {
-	BaseURL          string  `yaml:"base-url"`
-	APIKey           string  `yaml:"api-key"`
-	ModelName        string  `yaml:"model-name"`
-	MaxTokens        int     `yaml:"max-tokens"`
-	Temperature      float64 `yaml:"temperature"`
-	TopP             float64 `yaml:"top-p"`
-	FrequencyPenalty float64 `yaml:"frequency-penalty"`
+	Provider         string       `yaml:"provider"` // 模型后端：openai（默认，OpenAI 兼容接口）或 anthropic（Messages 接口）
+	BaseURL          string       `yaml:"base-url"`
+	APIKey           string       `yaml:"api-key"`
+	ModelName        string       `yaml:"model-name"`
+	MaxTokens        int          `yaml:"max-tokens"`
+	Temperature      float64      `yaml:"temperature"`
+	TopP             float64      `yaml:"top-p"`
+	FrequencyPenalty float64      `yaml:"frequency-penalty"`
+	Timeout          int          `yaml:"timeout"`       // 单次请求超时（秒）
+	Retry            *RetryConfig `yaml:"retry"`         // 失败重试策略
+	OutputMode       string       `yaml:"output-mode"`   // 输出模式（仅决策模型）：tags、tools 或 json_schema
+	MemoryBudget     int          `yaml:"memory-budget"` // 对话记忆 token 预算（仅决策模型）
+	Stream           *bool        `yaml:"stream"`        // 是否使用流式请求，为空时使用流式
+
+	Fallbacks      []*ModelConfig `yaml:"fallbacks"`       // 备用模型，按顺序故障转移；未设置的字段继承本模型
+	CircuitBreaker *BreakerConfig `yaml:"circuit-breaker"` // 熔断策略，为空时使用默认值
+}
+
+// BreakerConfig 模型熔断配置
+type BreakerConfig struct {
+	FailureThreshold int     `yaml:"failure-threshold"` // 连续失败多少次后熔断
+	Cooldown         float64 `yaml:"cooldown"`          // 熔断持续时间（秒）
+}
+
+// FallbackConfigs 返回备用模型配置，未设置（零值）的字段继承本模型
+func (m *ModelConfig) FallbackConfigs() []*ModelConfig {
+	configs := make([]*ModelConfig, 0, len(m.Fallbacks))
+	for _, fallback := range m.Fallbacks {
+		if fallback == nil {
+			continue
+		}
+		merged := *fallback
+		merged.Fallbacks = nil
+		if merged.Provider == "" {
+			merged.Provider = m.Provider
+		}
+		if merged.BaseURL == "" {
+			merged.BaseURL = m.BaseURL
+		}
+		if merged.APIKey == "" {
+			merged.APIKey = m.APIKey
+		}
+		if merged.ModelName == "" {
+			merged.ModelName = m.ModelName
+		}
+		if merged.MaxTokens == 0 {
+			merged.MaxTokens = m.MaxTokens
+		}
+		if merged.Temperature == 0 {
+			merged.Temperature = m.Temperature
+		}
+		if merged.TopP == 0 {
+			merged.TopP = m.TopP
+		}
+		if merged.FrequencyPenalty == 0 {
+			merged.FrequencyPenalty = m.FrequencyPenalty
+		}
+		if merged.Timeout == 0 {
+			merged.Timeout = m.Timeout
+		}
+		if merged.Retry == nil {
+			merged.Retry = m.Retry
+		}
+		if merged.Stream == nil {
+			merged.Stream = m.Stream
+		}
+		if merged.CircuitBreaker == nil {
+			merged.CircuitBreaker = m.CircuitBreaker
+		}
+		// 输出模式和记忆预算由首选模型决定
+		merged.OutputMode = m.OutputMode
+		merged.MemoryBudget = m.MemoryBudget
+		configs = append(configs, &merged)
+	}
+	return configs
+}
+
+// RetryConfig 模型请求重试配置
+type RetryConfig struct {
+	MaxAttempts    int     `yaml:"max-attempts"`    // 最大尝试次数（含首次），1 表示不重试
+	InitialBackoff float64 `yaml:"initial-backoff"` // 第一次重试前等待（秒）
+	MaxBackoff     float64 `yaml:"max-backoff"`     // 单次等待上限（秒），Retry-After 超过时不再重试
+	Multiplier     float64 `yaml:"multiplier"`      // 等待时间倍数
+	Jitter         float64 `yaml:"jitter"`          // 随机抖动比例（0-1）
+}
+
+// DefaultRetryConfig 返回默认重试配置
+func DefaultRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 1,
+		MaxBackoff:     30,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
 }
 
 // DecisionConfig 决策模型配置（从 model 包移过来，避免循环导入）
 type DecisionConfig struct {
-	Decision *ModelConfig `yaml:"decision"`
-	Vision   *ModelConfig `yaml:"vision"`
+	Decision   *ModelConfig `yaml:"decision"`
+	Vision     *ModelConfig `yaml:"vision"`
+	Coordinate *ModelConfig `yaml:"coordinate"` // 坐标识别模型，为空时使用 vision
+}
+
+// ScreenshotConfig 截图处理配置（发送给视觉模型前缩放/压缩）
+type ScreenshotConfig struct {
+	MaxLongEdge int  `yaml:"max-long-edge"`
+	JPEGQuality int  `yaml:"jpeg-quality"`
+	Grayscale   bool `yaml:"grayscale"`
+}
+
+// ServerConfig HTTP API 服务配置（serve 模式）
+type ServerConfig struct {
+	Addr  string `yaml:"addr"`  // 监听地址
+	Token string `yaml:"token"` // API 令牌，非空时要求 Authorization: Bearer <token>
+}
+
+// ApprovalConfig 敏感操作确认和人工接管的方式（无人值守运行时使用远程审批）
+type ApprovalConfig struct {
+	Mode              string   `yaml:"mode"`               // terminal、webhook 或 queue；为空时命令行用 terminal，serve 模式用 queue
+	WebhookURL        string   `yaml:"webhook-url"`        // webhook 模式的地址
+	WebhookToken      string   `yaml:"webhook-token"`      // webhook 令牌，发送 Authorization: Bearer <token>
+	Timeout           float64  `yaml:"timeout"`            // 等待人工决定的时间（秒）
+	OnTimeout         string   `yaml:"on-timeout"`         // 确认请求超时后的默认决定：deny 或 approve
+	SensitiveKeywords []string `yaml:"sensitive-keywords"` // 点击目标命中关键词时先请求确认，超时后总是拒绝
+}
+
+// PoolConfig 多设备并行执行任务队列（--tasks 模式）
+type PoolConfig struct {
+	Devices        []string `yaml:"devices"`         // 使用的设备，为空时使用所有已连接的设备
+	HealthInterval float64  `yaml:"health-interval"` // 设备发现与健康检查间隔（秒）
+	MaxAttempts    int      `yaml:"max-attempts"`    // 任务因设备离线失败时最多尝试的次数（含首次）
+}
+
+// PricingConfig 模型单价，用于按 token 用量计算费用
+type PricingConfig struct {
+	Currency string                 `yaml:"currency"` // 币种，仅用于显示
+	Models   map[string]PriceConfig `yaml:"models"`   // 键为模型名称（model-name），未列出的模型费用为 0
+}
+
+// PriceConfig 单个模型的单价（每百万 token）
+type PriceConfig struct {
+	Input  float64 `yaml:"input"`  // 输入 token
+	Output float64 `yaml:"output"` // 输出 token
+	Image  float64 `yaml:"image"`  // 图片 token，为 0 时按输入单价计
+}
+
+// VisionCacheConfig 视觉模型响应缓存（屏幕分析、坐标识别）
+type VisionCacheConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	TTL          float64 `yaml:"ttl"`            // 条目有效期（秒），0 表示不过期
+	MaxEntries   int     `yaml:"max-entries"`    // 最多保留的条目数，0 表示不限
+	Dir          string  `yaml:"dir"`            // 磁盘存储目录，为空时只在内存中缓存
+	Perceptual   bool    `yaml:"perceptual"`     // 按截图感知指纹匹配相似画面（忽略状态栏）
+	MaxDistance  int     `yaml:"max-distance"`   // 感知匹配允许的最大 dHash 汉明距离（0-64）
+	MaxPixelDiff float64 `yaml:"max-pixel-diff"` // 感知匹配允许的最大变化像素比例（0-1）
 }
 
 // Config 总配置结构
 type Config struct {
-	Agent    *AgentConfig    `yaml:"agent"`
-	Decision *DecisionConfig `yaml:"decision"`
+	Agent       *AgentConfig       `yaml:"agent"`
+	Decision    *DecisionConfig    `yaml:"decision"`
+	Screenshot  *ScreenshotConfig  `yaml:"screenshot"`
+	Server      *ServerConfig      `yaml:"server"`
+	Approval    *ApprovalConfig    `yaml:"approval"`
+	Pool        *PoolConfig        `yaml:"pool"`
+	Pricing     *PricingConfig     `yaml:"pricing"`
+	VisionCache *VisionCacheConfig `yaml:"vision-cache"`
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
 		Agent: &AgentConfig{
-			MaxSteps:     100,
-			DeviceID:     "",
-			SystemPrompt: "",
-			Verbose:      true,
+			MaxSteps:       100,
+			DeviceID:       "",
+			SystemPrompt:   "",
+			Verbose:        true,
+			ScreenSource:   "vision",
+			CommandTimeout: 30,
+			VerifyActions:  true,
+			VerifyDelay:    0.5,
+
+			LoopDetection:      true,
+			LoopWindow:         8,
+			LoopThreshold:      3,
+			RecoveryStrategies: []string{"hint", "back", "relaunch", "takeover"},
 		},
 		Decision: &DecisionConfig{
 			Decision: &ModelConfig{
+				Provider:         "openai",
 				BaseURL:          "https://api.deepseek.com",
 				APIKey:           "EMPTY",
 				ModelName:        "deepseek-chat",
@@ -58,8 +228,13 @@ func DefaultConfig() *Config {
 				Temperature:      0.7,
 				TopP:             0.9,
 				FrequencyPenalty: 0.0,
+				Timeout:          60,
+				Retry:            DefaultRetryConfig(),
+				OutputMode:       "tags",
+				MemoryBudget:     6000,
 			},
 			Vision: &ModelConfig{
+				Provider:         "openai",
 				BaseURL:          "https://open.bigmodel.cn/api/paas/v4",
 				APIKey:           "EMPTY",
 				ModelName:        "autoglm-phone",
@@ -67,8 +242,32 @@ func DefaultConfig() *Config {
 				Temperature:      0.0,
 				TopP:             0.85,
 				FrequencyPenalty: 0.2,
+				Timeout:          60,
+				Retry:            DefaultRetryConfig(),
 			},
 		},
+		Screenshot: &ScreenshotConfig{
+			MaxLongEdge: 0,
+			JPEGQuality: 0,
+			Grayscale:   false,
+		},
+		Server: &ServerConfig{
+			Addr: "127.0.0.1:8080",
+		},
+		Approval: &ApprovalConfig{
+			Timeout:           300,
+			OnTimeout:         "deny",
+			SensitiveKeywords: []string{"支付", "付款", "转账", "密码", "购买", "下单", "pay", "transfer", "password", "purchase"},
+		},
+		Pool: &PoolConfig{
+			HealthInterval: 30,
+			MaxAttempts:    2,
+		},
+		VisionCache: &VisionCacheConfig{
+			TTL:          600,
+			MaxEntries:   1000,
+			MaxPixelDiff: 0.002,
+		},
 	}
 }
 
@@ -207,16 +406,122 @@ func FindConfigFile() string {
 func (c *Config) Validate() error {
 	if c.Decision != nil {
 		if c.Decision.Decision != nil {
-			if c.Decision.Decision.BaseURL == "" {
-				return fmt.Errorf("decision.base-url is required")
+			if err := c.Decision.Decision.validate("decision"); err != nil {
+				return err
+			}
+			if c.Decision.Decision.MemoryBudget < 0 {
+				return fmt.Errorf("decision.memory-budget must not be negative")
+			}
+			switch c.Decision.Decision.OutputMode {
+			case "", "tags", "tools", "json_schema":
+			default:
+				return fmt.Errorf("decision.output-mode must be one of tags, tools, json_schema")
 			}
 		}
 		if c.Decision.Vision != nil {
-			if c.Decision.Vision.BaseURL == "" {
-				return fmt.Errorf("vision.base-url is required")
+			if err := c.Decision.Vision.validate("vision"); err != nil {
+				return err
+			}
+		}
+		if c.Decision.Coordinate != nil {
+			if err := c.Decision.Coordinate.validate("coordinate"); err != nil {
+				return err
 			}
 		}
 	}
+	if c.Agent != nil {
+		if c.Agent.VerifyDelay < 0 {
+			return fmt.Errorf("agent.verify-delay must not be negative")
+		}
+		for _, strategy := range c.Agent.RecoveryStrategies {
+			switch strategy {
+			case "hint", "back", "relaunch", "takeover":
+			default:
+				return fmt.Errorf("agent.recovery-strategies: unknown strategy %q", strategy)
+			}
+		}
+		switch c.Agent.ScreenSource {
+		case "", "vision", "ui", "hybrid":
+		default:
+			return fmt.Errorf("agent.screen-source must be one of vision, ui, hybrid")
+		}
+	}
+	if c.Pool != nil {
+		if c.Pool.HealthInterval < 0 {
+			return fmt.Errorf("pool.health-interval must not be negative")
+		}
+		if c.Pool.MaxAttempts < 0 {
+			return fmt.Errorf("pool.max-attempts must not be negative")
+		}
+	}
+	if c.Pricing != nil {
+		for name, price := range c.Pricing.Models {
+			if price.Input < 0 || price.Output < 0 || price.Image < 0 {
+				return fmt.Errorf("pricing.models.%s prices must not be negative", name)
+			}
+		}
+	}
+	if c.VisionCache != nil {
+		if c.VisionCache.TTL < 0 {
+			return fmt.Errorf("vision-cache.ttl must not be negative")
+		}
+		if c.VisionCache.MaxEntries < 0 {
+			return fmt.Errorf("vision-cache.max-entries must not be negative")
+		}
+		if c.VisionCache.MaxDistance < 0 || c.VisionCache.MaxDistance > 64 {
+			return fmt.Errorf("vision-cache.max-distance must be between 0 and 64")
+		}
+		if c.VisionCache.MaxPixelDiff < 0 || c.VisionCache.MaxPixelDiff > 1 {
+			return fmt.Errorf("vision-cache.max-pixel-diff must be between 0 and 1")
+		}
+	}
+	if c.Approval != nil {
+		switch c.Approval.Mode {
+		case "", "terminal", "queue":
+		case "webhook":
+			if c.Approval.WebhookURL == "" {
+				return fmt.Errorf("approval.webhook-url is required for webhook mode")
+			}
+		default:
+			return fmt.Errorf("approval.mode must be one of terminal, webhook, queue")
+		}
+		if c.Approval.Timeout < 0 {
+			return fmt.Errorf("approval.timeout must not be negative")
+		}
+		switch c.Approval.OnTimeout {
+		case "", "deny", "approve":
+		default:
+			return fmt.Errorf("approval.on-timeout must be deny or approve")
+		}
+	}
+	if c.Screenshot != nil {
+		if c.Screenshot.MaxLongEdge < 0 {
+			return fmt.Errorf("screenshot.max-long-edge must not be negative")
+		}
+		if c.Screenshot.JPEGQuality < 0 || c.Screenshot.JPEGQuality > 100 {
+			return fmt.Errorf("screenshot.jpeg-quality must be between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// validate 验证重试配置
+func (r *RetryConfig) validate(name string) error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("%s.max-attempts must not be negative", name)
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("%s backoff must not be negative", name)
+	}
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("%s.multiplier must be at least 1", name)
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("%s.jitter must be between 0 and 1", name)
+	}
 	return nil
 }
 
@@ -242,6 +547,17 @@ func (c *Config) MergeWithFlags(flags *Flags) {
 	if flags.Quiet {
 		c.Agent.Verbose = false
 	}
+	if flags.TraceDir != "" {
+		c.Agent.TraceDir = flags.TraceDir
+	}
+
+	// Server 配置
+	if c.Server == nil {
+		c.Server = &ServerConfig{Addr: "127.0.0.1:8080"}
+	}
+	if flags.Listen != "" {
+		c.Server.Addr = flags.Listen
+	}
 
 	// Decision 配置
 	if c.Decision == nil {
@@ -277,20 +593,22 @@ func (c *Config) MergeWithFlags(flags *Flags) {
 
 // Flags 命令行参数结构
 type Flags struct {
-	MaxSteps       int
-	DeviceID       string
-	Quiet          bool
-	LogEnabled     bool
-	ListDevices    bool
-	Connect        string
-	Disconnect     string
-	DecisionURL    string
-	DecisionKey    string
-	DecisionModel  string
-	VisionURL      string
-	VisionKey      string
-	VisionModel    string
-	ConfigFile     string
+	MaxSteps      int
+	DeviceID      string
+	Quiet         bool
+	LogEnabled    bool
+	ListDevices   bool
+	Connect       string
+	Disconnect    string
+	DecisionURL   string
+	DecisionKey   string
+	DecisionModel string
+	VisionURL     string
+	VisionKey     string
+	VisionModel   string
+	ConfigFile    string
+	TraceDir      string
+	Listen        string
 }
 
 // GetAPIKeysFromEnv 从环境变量获取 API 密钥
@@ -313,6 +631,13 @@ func (c *Config) GetAPIKeysFromEnv() {
 		}
 	}
 
+	// Server API token
+	if c.Server != nil && c.Server.Token == "" {
+		if token := os.Getenv("PHONE_AGENT_API_TOKEN"); token != "" {
+			c.Server.Token = token
+		}
+	}
+
 	// Device ID from env
 	if c.Agent != nil {
 		if c.Agent.DeviceID == "" {
@@ -323,6 +648,43 @@ func (c *Config) GetAPIKeysFromEnv() {
 	}
 }
 
+// validate 校验单个角色的模型配置及其备用模型
+func (m *ModelConfig) validate(field string) error {
+	if m.BaseURL == "" {
+		return fmt.Errorf("%s.base-url is required", field)
+	}
+	if err := m.Retry.validate(field + ".retry"); err != nil {
+		return err
+	}
+	if err := validateProvider(field+".provider", m.Provider); err != nil {
+		return err
+	}
+	if m.CircuitBreaker != nil {
+		if m.CircuitBreaker.FailureThreshold < 0 {
+			return fmt.Errorf("%s.circuit-breaker.failure-threshold must not be negative", field)
+		}
+		if m.CircuitBreaker.Cooldown < 0 {
+			return fmt.Errorf("%s.circuit-breaker.cooldown must not be negative", field)
+		}
+	}
+	for i, fallback := range m.FallbackConfigs() {
+		if err := fallback.validate(fmt.Sprintf("%s.fallbacks[%d]", field, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProvider 检查模型后端名称
+func validateProvider(field string, provider string) error {
+	switch provider {
+	case "", "openai", "anthropic":
+		return nil
+	default:
+		return fmt.Errorf("%s must be openai or anthropic", field)
+	}
+}
+
 // RedactSensitiveInfo 隐藏敏感信息（用于显示）
 func (c *Config) RedactSensitiveInfo() string {
 	redacted := *c
@@ -334,6 +696,19 @@ func (c *Config) RedactSensitiveInfo() string {
 		if redacted.Decision.Vision != nil && redacted.Decision.Vision.APIKey != "" {
 			redacted.Decision.Vision.APIKey = maskAPIKey(redacted.Decision.Vision.APIKey)
 		}
+		if redacted.Decision.Coordinate != nil && redacted.Decision.Coordinate.APIKey != "" {
+			redacted.Decision.Coordinate.APIKey = maskAPIKey(redacted.Decision.Coordinate.APIKey)
+		}
+	}
+	if redacted.Approval != nil && redacted.Approval.WebhookToken != "" {
+		approval := *redacted.Approval
+		approval.WebhookToken = maskAPIKey(approval.WebhookToken)
+		redacted.Approval = &approval
+	}
+	if redacted.Server != nil && redacted.Server.Token != "" {
+		server := *redacted.Server
+		server.Token = maskAPIKey(server.Token)
+		redacted.Server = &server
 	}
 
 	data, _ := yaml.Marshal(redacted)
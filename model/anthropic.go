@@ -0,0 +1,459 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	anthropicVersion   = "2023-06-01" // anthropic-version 请求头
+	anthropicMaxTokens = 4096         // 未配置 max-tokens 时的默认值（该接口要求必填）
+)
+
+// AnthropicProvider Anthropic Messages 接口（/messages）
+//
+// 系统提示词作为顶层 system 字段发送，图片转换为 base64 image 内容块，使用 x-api-key 认证。
+// BaseURL 与 OpenAI 兼容接口一样包含版本路径，例如 https://api.anthropic.com/v1。
+type AnthropicProvider struct {
+	config     *ModelConfig
+	mu         sync.RWMutex // 保护 httpClient 的替换
+	httpClient *http.Client
+}
+
+// NewAnthropicProvider 创建 Anthropic Messages 接口的后端
+func NewAnthropicProvider(config *ModelConfig) *AnthropicProvider {
+	return &AnthropicProvider{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+// SetTransport 替换底层 HTTP 传输，nil 恢复默认
+func (p *AnthropicProvider) SetTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.httpClient = &http.Client{Transport: rt}
+}
+
+// anthropicRequest Messages 接口请求
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  interface{}        `json:"tool_choice,omitempty"`
+}
+
+// anthropicMessage 对话消息，角色只有 user 和 assistant
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock 消息内容块（text 或 image）
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+// anthropicImageSource base64 图片
+type anthropicImageSource struct {
+	Type      string `json:"type"` // 固定为 base64
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// anthropicTool 工具定义
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// anthropicResponseBlock 响应内容块（text 或 tool_use）
+type anthropicResponseBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// anthropicResponse 非流式响应
+type anthropicResponse struct {
+	Content    []anthropicResponseBlock `json:"content"`
+	StopReason string                   `json:"stop_reason"`
+	Usage      anthropicUsage           `json:"usage"`
+}
+
+// anthropicUsage 响应中的 usage 字段，图片 token 不单独返回
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// promptTokens 输入 token，包含缓存写入和命中的部分
+func (u anthropicUsage) promptTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// Complete 发送非流式请求
+func (p *AnthropicProvider) Complete(ctx context.Context, req *ChatRequest) (*ModelResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msgResp anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request aborted: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var rawContent strings.Builder
+	response := &ModelResponse{Usage: Usage{
+		PromptTokens:     msgResp.Usage.promptTokens(),
+		CompletionTokens: msgResp.Usage.OutputTokens,
+	}}
+	for _, block := range msgResp.Content {
+		switch block.Type {
+		case "text":
+			rawContent.WriteString(block.Text)
+		case "tool_use":
+			response.ToolCalls = append(response.ToolCalls, ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
+		}
+	}
+	response.RawContent = rawContent.String()
+	return response, nil
+}
+
+// Stream 发送流式请求，按 SSE 事件类型拼接文本和工具调用，收到 message_stop 视为完成
+//
+// 输入 token 在 message_start 中返回，输出 token 在 message_delta 中累计返回。
+func (p *AnthropicProvider) Stream(ctx context.Context, req *ChatRequest, onDelta func(StreamDelta)) (*ModelResponse, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	completed := false
+	var rawContent strings.Builder
+	toolCalls := newToolCallAccumulator()
+	var usage Usage
+
+	for scanner.Scan() && !completed {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event struct {
+			Type    string `json:"type"`
+			Index   int    `json:"index"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Usage        anthropicUsage         `json:"usage"`
+			ContentBlock anthropicResponseBlock `json:"content_block"`
+			Delta        struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.promptTokens()
+			usage.CompletionTokens = event.Message.Usage.OutputTokens
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				delta := toolCallDelta{Index: event.Index, ID: event.ContentBlock.ID}
+				delta.Function.Name = event.ContentBlock.Name
+				toolCalls.add(delta)
+				if onDelta != nil {
+					onDelta(StreamDelta{ToolCall: true})
+				}
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				if event.Delta.Text == "" {
+					continue
+				}
+				rawContent.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(StreamDelta{Content: event.Delta.Text})
+				}
+			case "input_json_delta":
+				delta := toolCallDelta{Index: event.Index}
+				delta.Function.Arguments = event.Delta.PartialJSON
+				toolCalls.add(delta)
+				if onDelta != nil {
+					onDelta(StreamDelta{ToolCall: true})
+				}
+			}
+		case "message_stop":
+			completed = true
+		case "error":
+			// 流中途的错误（如 overloaded_error）按对应状态码返回，便于重试判断
+			return nil, &APIError{
+				StatusCode: anthropicErrorStatus(event.Error.Type),
+				Body:       data,
+			}
+		}
+	}
+
+	// 流被取消或超时中断
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("request aborted: %w", err)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTruncatedStream, err)
+	}
+	if !completed {
+		return nil, ErrTruncatedStream
+	}
+
+	calls := toolCalls.calls()
+	for i := range calls {
+		// 无参数的工具不会发送 input_json_delta
+		if calls[i].Arguments == "" {
+			calls[i].Arguments = "{}"
+		}
+	}
+	return &ModelResponse{
+		RawContent: rawContent.String(),
+		ToolCalls:  calls,
+		Usage:      usage,
+	}, nil
+}
+
+// anthropicErrorStatus 流式错误事件类型对应的 HTTP 状态码
+func anthropicErrorStatus(errorType string) int {
+	switch errorType {
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	case "api_error":
+		return http.StatusInternalServerError
+	case "authentication_error":
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// send 构建并发送请求，非 200 响应返回 APIError
+func (p *AnthropicProvider) send(ctx context.Context, req *ChatRequest, stream bool) (*http.Response, error) {
+	msgReq, err := p.buildRequest(req, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody, err := json.Marshal(msgReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/messages", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.config.APIKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	p.mu.RLock()
+	httpClient := p.httpClient
+	p.mu.RUnlock()
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+// buildRequest 把 ChatRequest 转换为 Messages 接口请求
+//
+// system 角色的消息合并到顶层 system 字段；连续的同角色消息合并为一条（该接口要求 user/assistant 交替）。
+// 当前模型不允许同时设置 temperature 和 top_p，只发送 temperature；frequency-penalty 没有对应参数，忽略。
+func (p *AnthropicProvider) buildRequest(req *ChatRequest, stream bool) (*anthropicRequest, error) {
+	msgReq := &anthropicRequest{
+		Model:       p.config.ModelName,
+		MaxTokens:   p.config.MaxTokens,
+		Temperature: p.config.Temperature,
+		Stream:      stream,
+	}
+	if msgReq.MaxTokens <= 0 {
+		msgReq.MaxTokens = anthropicMaxTokens
+	}
+
+	var system []string
+	if req.System != nil {
+		system = append(system, messageText(req.System.Content))
+	}
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			system = append(system, messageText(message.Content))
+			continue
+		}
+		blocks, err := anthropicContent(message.Content)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(msgReq.Messages); n > 0 && msgReq.Messages[n-1].Role == message.Role {
+			msgReq.Messages[n-1].Content = append(msgReq.Messages[n-1].Content, blocks...)
+			continue
+		}
+		msgReq.Messages = append(msgReq.Messages, anthropicMessage{Role: message.Role, Content: blocks})
+	}
+
+	if opts := req.Options; opts != nil {
+		for _, tool := range opts.Tools {
+			msgReq.Tools = append(msgReq.Tools, anthropicTool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: tool.Function.Parameters,
+			})
+		}
+		if len(msgReq.Tools) > 0 {
+			msgReq.ToolChoice = anthropicToolChoice(opts.ToolChoice)
+		}
+		// 没有 response_format，改为在系统提示词中要求按 schema 输出 JSON
+		if format := opts.ResponseFormat; format != nil && format.JSONSchema != nil {
+			schema, err := json.Marshal(format.JSONSchema.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal response schema: %w", err)
+			}
+			system = append(system, "只输出一个符合以下 JSON Schema 的 JSON 对象，不要输出其他内容：\n"+string(schema))
+		}
+	}
+
+	msgReq.System = strings.Join(system, "\n\n")
+	return msgReq, nil
+}
+
+// anthropicToolChoice 把 OpenAI 的 tool_choice 转换为 Messages 接口格式
+func anthropicToolChoice(choice interface{}) interface{} {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "required":
+			return map[string]string{"type": "any"}
+		case "none":
+			return map[string]string{"type": "none"}
+		default:
+			return map[string]string{"type": "auto"}
+		}
+	case map[string]interface{}:
+		// {"type": "function", "function": {"name": "..."}}
+		if function, ok := c["function"].(map[string]interface{}); ok {
+			if name, ok := function["name"].(string); ok {
+				return map[string]string{"type": "tool", "name": name}
+			}
+		}
+	}
+	return map[string]string{"type": "auto"}
+}
+
+// anthropicContent 把消息内容（文本或 CreateImageMessage 生成的内容列表）转换为内容块
+func anthropicContent(content interface{}) ([]anthropicContentBlock, error) {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil, nil
+		}
+		return []anthropicContentBlock{{Type: "text", Text: c}}, nil
+	case []ImageContent:
+		blocks := make([]anthropicContentBlock, 0, len(c))
+		for _, item := range c {
+			switch item.Type {
+			case "text":
+				if item.Text != "" {
+					blocks = append(blocks, anthropicContentBlock{Type: "text", Text: item.Text})
+				}
+			case "image_url":
+				source, err := parseDataURI(item.ImageURL.URL)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, anthropicContentBlock{Type: "image", Source: source})
+			}
+		}
+		return blocks, nil
+	default:
+		return nil, fmt.Errorf("unsupported message content: %T", content)
+	}
+}
+
+// parseDataURI 解析 data:<mime>;base64,<data> 形式的图片地址
+func parseDataURI(uri string) (*anthropicImageSource, error) {
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported image url: only base64 data URIs are supported")
+	}
+	mediaType, data, ok := strings.Cut(rest, ";base64,")
+	if !ok {
+		return nil, fmt.Errorf("unsupported image url: not base64 encoded")
+	}
+	return &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// messageText 取消息内容中的文本
+func messageText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []ImageContent:
+		var parts []string
+		for _, item := range c {
+			if item.Type == "text" && item.Text != "" {
+				parts = append(parts, item.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return fmt.Sprint(content)
+	}
+}
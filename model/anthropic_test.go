@@ -0,0 +1,134 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// anthropicStream 按顺序输出 SSE 事件
+func anthropicStream(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+	}
+}
+
+func TestAnthropicProviderStream(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %s, want /v1/messages", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("x-api-key = %q, want test-key", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+			t.Errorf("anthropic-version = %q, want %s", got, anthropicVersion)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		anthropicStream(w,
+			`{"type":"message_start","message":{"usage":{"input_tokens":120,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"屏幕上"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"有登录按钮"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":8}}`,
+			`{"type":"message_stop"}`,
+		)
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&ModelConfig{
+		BaseURL:     server.URL + "/v1",
+		APIKey:      "test-key",
+		ModelName:   "claude-test",
+		Temperature: 0.2,
+		TopP:        0.9,
+	})
+	system := CreateSystemMessage("描述屏幕")
+	req := &ChatRequest{
+		System:   &system,
+		Messages: []Message{CreateImageMessage("描述屏幕内容", "aGVsbG8=", "image/jpeg")},
+	}
+
+	var streamed string
+	response, err := provider.Stream(context.Background(), req, func(delta StreamDelta) {
+		streamed += delta.Content
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if response.RawContent != "屏幕上有登录按钮" || streamed != response.RawContent {
+		t.Errorf("content = %q, streamed %q", response.RawContent, streamed)
+	}
+	if response.Usage.PromptTokens != 120 || response.Usage.CompletionTokens != 8 {
+		t.Errorf("usage = %+v, want 120/8", response.Usage)
+	}
+
+	if body["system"] != "描述屏幕" {
+		t.Errorf("system = %v, want top-level system prompt", body["system"])
+	}
+	if _, ok := body["top_p"]; ok {
+		t.Errorf("top_p should not be sent together with temperature")
+	}
+	if body["temperature"] != 0.2 {
+		t.Errorf("temperature = %v, want 0.2", body["temperature"])
+	}
+	messages := body["messages"].([]interface{})
+	if len(messages) != 1 {
+		t.Fatalf("messages = %d, want 1 (system prompt must not be a message)", len(messages))
+	}
+	content := messages[0].(map[string]interface{})["content"].([]interface{})
+	image := content[0].(map[string]interface{})
+	source, _ := image["source"].(map[string]interface{})
+	if image["type"] != "image" || source["type"] != "base64" || source["media_type"] != "image/jpeg" || source["data"] != "aGVsbG8=" {
+		t.Errorf("image block = %v, want base64 image/jpeg", image)
+	}
+	if text := content[1].(map[string]interface{}); text["type"] != "text" || text["text"] != "描述屏幕内容" {
+		t.Errorf("text block = %v", text)
+	}
+}
+
+func TestAnthropicProviderStreamErrorEvent(t *testing.T) {
+	tests := []struct {
+		errorType string
+		status    int
+		retryable bool
+	}{
+		{"overloaded_error", 529, true},
+		{"rate_limit_error", http.StatusTooManyRequests, true},
+		{"api_error", http.StatusInternalServerError, true},
+		{"authentication_error", http.StatusUnauthorized, false},
+		{"invalid_request_error", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.errorType, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				anthropicStream(w,
+					`{"type":"message_start","message":{"usage":{"input_tokens":10}}}`,
+					fmt.Sprintf(`{"type":"error","error":{"type":%q,"message":"boom"}}`, tt.errorType),
+				)
+			}))
+			defer server.Close()
+
+			provider := NewAnthropicProvider(&ModelConfig{BaseURL: server.URL, ModelName: "claude-test"})
+			_, err := provider.Stream(context.Background(), &ChatRequest{Messages: []Message{CreateUserMessage("hi", "")}}, nil)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if IsRetryable(err) != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", IsRetryable(err), tt.retryable)
+			}
+		})
+	}
+}
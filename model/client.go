@@ -1,175 +1,257 @@
 package model
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Client AI 模型客户端
+// Client 某个角色（决策、屏幕分析、坐标识别）的模型客户端，请求可以并发发送
+//
+// Client 在 Provider 之上统一处理系统提示词、超时、重试、故障转移、耗时统计和响应解析。
+// 配置了 ModelConfig.Fallbacks 时，首选模型在可重试的错误（重试用尽后）或熔断时依次切换到备用模型。
 type Client struct {
-	config     *ModelConfig
-	httpClient *http.Client
+	config       *ModelConfig // 首选模型配置
+	endpoints    []*endpoint  // 首选模型及备用模型，按尝试顺序排列
+	mu           sync.RWMutex // 保护 SystemPrompt 和用量统计的替换
+	SystemPrompt *Message     // 缓存的系统提示词（公开以便日志记录，替换请使用 SetSystemPrompt）
+	meter        *UsageMeter  // 用量统计，nil 时不统计
+	role         string       // 记录用量时使用的角色
 }
 
-// NewClient 创建模型客户端
+// NewClient 创建模型客户端，后端由 ModelConfig.Provider 决定
 func NewClient(config *ModelConfig) *Client {
+	return NewClientWithProvider(config, nil)
+}
+
+// NewClientWithProvider 使用指定后端创建模型客户端，provider 为 nil 时按 ModelConfig.Provider 创建；
+// 备用模型的后端总是按各自的配置创建
+func NewClientWithProvider(config *ModelConfig, provider Provider) *Client {
+	endpoints := []*endpoint{newEndpoint(config, provider)}
+	for _, fallback := range config.Fallbacks {
+		endpoints = append(endpoints, newEndpoint(fallback, nil))
+	}
 	return &Client{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		config:    config,
+		endpoints: endpoints,
 	}
 }
 
-// Request 发送请求到模型
-func (c *Client) Request(messages []Message) (*ModelResponse, error) {
-	startTime := time.Now()
-	var timeToFirstToken, timeToThinkingEnd float64
-
-	// 构建请求
-	req := &ChatCompletionRequest{
-		Messages:         messages,
-		Model:            c.config.ModelName,
-		MaxTokens:        c.config.MaxTokens,
-		Temperature:      c.config.Temperature,
-		TopP:             c.config.TopP,
-		FrequencyPenalty: c.config.FrequencyPenalty,
-		Stream:           true,
+// NewClientWithSystemPrompt 创建带系统提示词的模型客户端
+func NewClientWithSystemPrompt(config *ModelConfig, systemPrompt string) *Client {
+	client := NewClient(config)
+	client.SystemPrompt = &Message{
+		Role:    "system",
+		Content: systemPrompt,
 	}
+	return client
+}
 
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// ModelName 返回首选模型名称
+func (c *Client) ModelName() string {
+	return c.config.ModelName
+}
+
+// SetSystemPrompt 设置系统提示词
+func (c *Client) SetSystemPrompt(prompt string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SystemPrompt = &Message{
+		Role:    "system",
+		Content: prompt,
 	}
+}
 
-	// 创建 HTTP 请求
-	httpReq, err := http.NewRequest("POST", c.config.BaseURL+"/chat/completions", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// systemPrompt 获取当前系统提示词
+func (c *Client) systemPrompt() *Message {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SystemPrompt
+}
+
+// SystemPromptText 返回系统提示词文本，未设置时为空
+func (c *Client) SystemPromptText() string {
+	if prompt := c.systemPrompt(); prompt != nil {
+		return messageText(prompt.Content)
 	}
+	return ""
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+// SetUsageMeter 设置用量统计，之后每次成功的请求按 role 记录用量和费用；meter 为 nil 时停止统计
+func (c *Client) SetUsageMeter(meter *UsageMeter, role string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.meter = meter
+	c.role = role
+}
 
-	// 发送请求
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+// recordUsage 把一次请求的用量按实际应答的模型记入用量统计
+func (c *Client) recordUsage(config *ModelConfig, usage Usage) {
+	c.mu.RLock()
+	meter, role := c.meter, c.role
+	c.mu.RUnlock()
+	if meter != nil {
+		meter.Record(role, config.ModelName, usage, config.Price.Cost(usage))
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+// SetTransport 替换底层 HTTP 传输（用于录制/回放模型响应），nil 恢复默认
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	for _, ep := range c.endpoints {
+		ep.provider.SetTransport(rt)
 	}
+}
 
-	// 处理流式响应
-	scanner := bufio.NewScanner(resp.Body)
-	rawContent := ""
-	buffer := ""
-	inActionPhase := false
-	firstTokenReceived := false
-	actionMarkers := []string{"finish(message=", "do(action="}
+// Request 发送请求到模型
+func (c *Client) Request(ctx context.Context, messages []Message) (*ModelResponse, error) {
+	return c.RequestWithSystem(ctx, messages, c.systemPrompt())
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
+// RequestWithSystem 使用指定系统提示词发送请求，可重试的错误按 ModelConfig.Retry 自动重试
+func (c *Client) RequestWithSystem(ctx context.Context, messages []Message, systemMsg *Message) (*ModelResponse, error) {
+	return c.RequestWithOptions(ctx, messages, systemMsg, nil)
+}
 
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
+// RequestWithOptions 带附加参数（如 tools）发送请求，opts 为 nil 时等同于 RequestWithSystem
+//
+// 每个模型按各自的重试策略重试；重试用尽仍是可重试的错误，或模型在重试期间熔断时，切换到下一个备用模型。
+// 不可重试的错误（如 400、401）直接返回。
+func (c *Client) RequestWithOptions(ctx context.Context, messages []Message, systemMsg *Message, opts *RequestOptions) (*ModelResponse, error) {
+	req := &ChatRequest{System: systemMsg, Messages: messages, Options: opts}
+
+	var lastErr error
+	for i, ep := range c.candidates() {
+		if i > 0 {
+			LogInfo(fmt.Sprintf("切换到备用模型 %s: %v", ep.config.ModelName, lastErr))
 		}
 
-		var streamResp struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-			} `json:"choices"`
+		attempt := 0
+		var attemptErr error
+		response, err := withRetry(ctx, ep.config.Retry, ep.config.ModelName, func() (*ModelResponse, error) {
+			// 首次尝试总是发送，重试前模型已熔断则不再重试
+			attempt++
+			if attempt > 1 && !ep.breaker.allow(time.Now()) {
+				return nil, fmt.Errorf("%s: %w (last error: %v)", ep.config.ModelName, ErrCircuitOpen, attemptErr)
+			}
+			response, err := c.requestOnce(ctx, ep, req)
+			attemptErr = err
+			return response, err
+		})
+		if err == nil {
+			return response, nil
 		}
-
-		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-			continue
+		lastErr = err
+		if !canFailover(err) || ctx.Err() != nil {
+			break
 		}
+	}
+	return nil, lastErr
+}
 
-		if len(streamResp.Choices) == 0 || streamResp.Choices[0].Delta.Content == "" {
-			continue
+// candidates 返回本次请求依次尝试的模型：跳过熔断中的模型，全部熔断时依次试探所有模型
+func (c *Client) candidates() []*endpoint {
+	now := time.Now()
+	var available []*endpoint
+	for _, ep := range c.endpoints {
+		if ep.breaker.allow(now) {
+			available = append(available, ep)
 		}
+	}
+	if len(available) == 0 {
+		return c.endpoints
+	}
+	return available
+}
 
-		content := streamResp.Choices[0].Delta.Content
-		rawContent += content
+// actionMarkers 思考结束、开始输出动作的标记，用于统计思考耗时
+var actionMarkers = []string{"finish(message=", "do(action="}
 
-		// 记录首字延迟
-		if !firstTokenReceived {
-			timeToFirstToken = time.Since(startTime).Seconds()
-			firstTokenReceived = true
+// requestOnce 向指定模型发送单次请求，受该模型的 Timeout 限制；可重试的失败计入熔断
+func (c *Client) requestOnce(ctx context.Context, ep *endpoint, req *ChatRequest) (*ModelResponse, error) {
+	response, err := c.send(ctx, ep, req)
+	if err != nil {
+		if IsRetryable(err) && ctx.Err() == nil && ep.breaker.failure(time.Now()) {
+			LogInfo(fmt.Sprintf("%s 连续失败 %d 次，熔断 %v", ep.config.ModelName, ep.breaker.policy.FailureThreshold, ep.breaker.policy.Cooldown))
 		}
+		return nil, err
+	}
+	ep.breaker.success()
+	c.recordUsage(ep.config, response.Usage)
+	return response, nil
+}
 
-		if inActionPhase {
-			continue
-		}
+// send 发送单次请求并解析响应
+func (c *Client) send(ctx context.Context, ep *endpoint, req *ChatRequest) (*ModelResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ep.config.requestTimeout())
+	defer cancel()
 
-		buffer += content
+	startTime := time.Now()
+	var timeToFirstToken, timeToThinkingEnd float64
 
-		// 检查是否有动作标记
-		markerFound := false
-		for _, marker := range actionMarkers {
-			if strings.Contains(buffer, marker) {
-				inActionPhase = true
-				markerFound = true
+	var response *ModelResponse
+	var err error
+	if ep.config.DisableStream {
+		response, err = ep.provider.Complete(ctx, req)
+	} else {
+		buffer := ""
+		inActionPhase := false
+		response, err = ep.provider.Stream(ctx, req, func(delta StreamDelta) {
+			// 记录首字延迟
+			if timeToFirstToken == 0 {
+				timeToFirstToken = time.Since(startTime).Seconds()
+			}
+			if inActionPhase || delta.Content == "" {
+				return
+			}
 
-				if timeToThinkingEnd == 0 {
+			buffer += delta.Content
+			for _, marker := range actionMarkers {
+				if strings.Contains(buffer, marker) {
+					inActionPhase = true
 					timeToThinkingEnd = time.Since(startTime).Seconds()
+					return
 				}
-				break
 			}
-		}
-
-		if markerFound {
-			continue
-		}
 
-		// 检查是否可能是标记的前缀
-		isPotentialMarker := false
-		for _, marker := range actionMarkers {
-			for i := 1; i < len(marker); i++ {
-				if strings.HasSuffix(buffer, marker[:i]) {
-					isPotentialMarker = true
-					break
-				}
+			// 只保留可能是标记前缀的尾部
+			if !hasMarkerPrefixSuffix(buffer) {
+				buffer = ""
 			}
-			if isPotentialMarker {
-				break
-			}
-		}
-
-		if !isPotentialMarker {
-			buffer = ""
-		}
+		})
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// 计算总时间
 	totalTime := time.Since(startTime).Seconds()
+	if ep.config.DisableStream {
+		timeToFirstToken = totalTime
+	}
 
 	// 解析响应
-	thinking, action := parseResponse(rawContent)
+	thinking, action := parseResponse(response.RawContent)
+	response.Thinking = thinking
+	response.Action = action
+	response.TimeToFirstToken = timeToFirstToken
+	response.TimeToThinkingEnd = timeToThinkingEnd
+	response.TotalTime = totalTime
+	response.Model = ep.config.ModelName
+	return response, nil
+}
 
-	return &ModelResponse{
-		Thinking:          thinking,
-		Action:            action,
-		RawContent:        rawContent,
-		TimeToFirstToken:  timeToFirstToken,
-		TimeToThinkingEnd: timeToThinkingEnd,
-		TotalTime:         totalTime,
-	}, nil
+// hasMarkerPrefixSuffix buffer 结尾是否可能是动作标记的前缀
+func hasMarkerPrefixSuffix(buffer string) bool {
+	for _, marker := range actionMarkers {
+		for i := 1; i < len(marker); i++ {
+			if strings.HasSuffix(buffer, marker[:i]) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // parseResponse 解析模型响应
@@ -208,15 +290,24 @@ func parseResponse(content string) (string, string) {
 	return "", content
 }
 
-// CreateUserMessage 创建用户消息
+// CreateUserMessage 创建用户消息（图片按 PNG 处理）
 func CreateUserMessage(text string, imageBase64 string) Message {
+	return CreateImageMessage(text, imageBase64, "image/png")
+}
+
+// CreateImageMessage 创建带指定 MIME 类型图片的用户消息
+func CreateImageMessage(text string, imageBase64 string, mimeType string) Message {
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+
 	content := []ImageContent{}
 
 	if imageBase64 != "" {
 		content = append(content, ImageContent{
 			Type: "image_url",
 		})
-		content[len(content)-1].ImageURL.URL = "data:image/png;base64," + imageBase64
+		content[len(content)-1].ImageURL.URL = "data:" + mimeType + ";base64," + imageBase64
 	}
 
 	content = append(content, ImageContent{
@@ -1,26 +1,67 @@
 package model
 
+import "time"
+
 // ModelConfig AI 模型配置
 type ModelConfig struct {
-	BaseURL          string  // 模型 API 地址
-	APIKey           string  // API 密钥
-	ModelName        string  // 模型名称
-	MaxTokens        int     // 最大 token 数
-	Temperature      float64 // 采样温度
-	TopP             float64 // Top-P 采样
-	FrequencyPenalty float64 // 频率惩罚
+	Provider         string      // 模型后端：openai（默认，OpenAI 兼容接口）或 anthropic
+	BaseURL          string      // 模型 API 地址
+	APIKey           string      // API 密钥
+	ModelName        string      // 模型名称
+	MaxTokens        int         // 最大 token 数
+	Temperature      float64     // 采样温度
+	TopP             float64     // Top-P 采样
+	FrequencyPenalty float64     // 频率惩罚
+	Timeout          int         // 单次请求超时（秒），0 使用默认值
+	Retry            RetryPolicy // 失败重试策略
+	OutputMode       string      // 输出模式（仅决策模型）：tags、tools 或 json_schema，空值按 tags 处理
+	MemoryBudget     int         // 对话记忆 token 预算（仅决策模型），超出后较早轮次压缩为摘要，0 使用默认值
+	DisableStream    bool        // 使用非流式请求（默认流式）
+	Price            *Price      // 单价，用于计算费用，nil 时费用为 0
+
+	Fallbacks []*ModelConfig // 备用模型，首选模型出现可重试的错误或熔断时按顺序切换（输出模式、记忆预算沿用首选模型）
+	Breaker   BreakerPolicy  // 熔断策略，首选和备用模型各自独立熔断
+}
+
+// 决策模型输出模式
+const (
+	OutputModeTags  = "tags"  // 从 <action>/<parameters> 等标签中解析计划
+	OutputModeTools = "tools" // 通过 tools/tool_calls 函数调用返回计划
+
+	OutputModeJSONSchema = "json_schema" // 通过 response_format 返回 JSON 计划
+)
+
+// defaultRequestTimeout 默认单次请求超时
+const defaultRequestTimeout = 60 * time.Second
+
+// requestTimeout 返回单次请求超时
+func (c *ModelConfig) requestTimeout() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout) * time.Second
+	}
+	return defaultRequestTimeout
 }
 
 // DecisionConfig 决策模型配置（双模型架构）
 type DecisionConfig struct {
-	Decision *ModelConfig // 决策模型，负责任务规划
-	Vision   *ModelConfig // 视觉模型，负责屏幕识别
+	Decision   *ModelConfig // 决策模型，负责任务规划
+	Vision     *ModelConfig // 视觉模型，负责屏幕识别
+	Coordinate *ModelConfig // 坐标识别模型，nil 时使用 Vision
+}
+
+// CoordinateConfig 坐标识别使用的模型配置
+func (c *DecisionConfig) CoordinateConfig() *ModelConfig {
+	if c.Coordinate != nil {
+		return c.Coordinate
+	}
+	return c.Vision
 }
 
 // DefaultDecisionConfig 返回默认决策模型配置
 func DefaultDecisionConfig() *DecisionConfig {
 	return &DecisionConfig{
 		Decision: &ModelConfig{
+			Provider:         ProviderOpenAI,
 			BaseURL:          "https://api.deepseek.com",
 			APIKey:           "EMPTY",
 			ModelName:        "deepseek-chat",
@@ -28,8 +69,12 @@ func DefaultDecisionConfig() *DecisionConfig {
 			Temperature:      0.7,
 			TopP:             0.9,
 			FrequencyPenalty: 0.0,
+			Retry:            DefaultRetryPolicy(),
+			OutputMode:       OutputModeTags,
+			MemoryBudget:     defaultMemoryBudget,
 		},
 		Vision: &ModelConfig{
+			Provider:         ProviderOpenAI,
 			BaseURL:          "https://open.bigmodel.cn/api/paas/v4",
 			APIKey:           "EMPTY",
 			ModelName:        "autoglm-phone",
@@ -37,18 +82,22 @@ func DefaultDecisionConfig() *DecisionConfig {
 			Temperature:      0.0,
 			TopP:             0.85,
 			FrequencyPenalty: 0.2,
+			Retry:            DefaultRetryPolicy(),
 		},
 	}
 }
 
 // ModelResponse 模型响应
 type ModelResponse struct {
-	Thinking          string  // 思考过程
-	Action            string  // 动作指令
-	RawContent        string  // 原始内容
-	TimeToFirstToken  float64 // 首字延迟(秒)
-	TimeToThinkingEnd float64 // 思考结束时间(秒)
-	TotalTime         float64 // 总时间(秒)
+	Thinking          string     // 思考过程
+	Action            string     // 动作指令
+	RawContent        string     // 原始内容
+	TimeToFirstToken  float64    // 首字延迟(秒)
+	TimeToThinkingEnd float64    // 思考结束时间(秒)
+	TotalTime         float64    // 总时间(秒)
+	ToolCalls         []ToolCall // 函数调用（仅 tools 模式）
+	Usage             Usage      // token 用量，后端未返回时为零值
+	Model             string     // 实际应答的模型名称，发生故障转移时为备用模型
 }
 
 // Message 对话消息
@@ -59,13 +108,62 @@ type Message struct {
 
 // ChatCompletionRequest 聊天完成请求
 type ChatCompletionRequest struct {
-	Messages         []Message `json:"messages"`
-	Model            string    `json:"model"`
-	MaxTokens        int       `json:"max_tokens,omitempty"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
-	Stream           bool      `json:"stream"`
+	Messages         []Message       `json:"messages"`
+	Model            string          `json:"model"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	TopP             float64         `json:"top_p,omitempty"`
+	FrequencyPenalty float64         `json:"frequency_penalty,omitempty"`
+	Stream           bool            `json:"stream"`
+	Tools            []Tool          `json:"tools,omitempty"`
+	ToolChoice       interface{}     `json:"tool_choice,omitempty"` // "auto"/"required" 或指定函数
+	ResponseFormat   *ResponseFormat `json:"response_format,omitempty"`
+	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+// StreamOptions 流式请求选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // 在最后一个数据块中返回 usage
+}
+
+// ResponseFormat 结构化输出格式
+type ResponseFormat struct {
+	Type       string            `json:"type"` // text、json_object 或 json_schema
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat json_schema 输出格式定义
+type JSONSchemaFormat struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+// Tool 函数调用工具定义（OpenAI tools 格式）
+type Tool struct {
+	Type     string       `json:"type"` // 固定为 function
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 工具函数定义
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema
+}
+
+// ToolCall 模型返回的函数调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 字符串
+}
+
+// RequestOptions 单次请求的附加参数
+type RequestOptions struct {
+	Tools          []Tool
+	ToolChoice     interface{}
+	ResponseFormat *ResponseFormat
 }
 
 // ImageContent 图片内容
@@ -73,6 +171,6 @@ type ImageContent struct {
 	Type     string `json:"type"` // text 或 image_url
 	Text     string `json:"text,omitempty"`
 	ImageURL struct {
-		URL string `json:"url"` // data:image/png;base64,xxx
+		URL string `json:"url"` // data:image/png;base64,xxx 或 data:image/jpeg;base64,xxx
 	} `json:"image_url,omitempty"`
 }
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerPolicy 熔断策略：某个模型连续失败达到阈值后，冷却期内不再向它发送请求
+type BreakerPolicy struct {
+	FailureThreshold int           // 连续失败多少次后熔断，0 使用默认值
+	Cooldown         time.Duration // 熔断持续时间，0 使用默认值
+}
+
+// DefaultBreakerPolicy 返回默认熔断策略
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		FailureThreshold: 3,
+		Cooldown:         60 * time.Second,
+	}
+}
+
+// withDefaults 用默认值补全未设置的字段
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	def := DefaultBreakerPolicy()
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = def.FailureThreshold
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = def.Cooldown
+	}
+	return p
+}
+
+// ErrCircuitOpen 模型已熔断，冷却结束前不再发送请求
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker 单个模型的熔断器
+//
+// 冷却结束后放行请求（半开）：成功则恢复，再次失败立即重新熔断。
+type circuitBreaker struct {
+	policy    BreakerPolicy
+	mu        sync.Mutex
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断结束时间，零值表示未熔断
+}
+
+// newCircuitBreaker 创建熔断器
+func newCircuitBreaker(policy BreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{policy: policy.withDefaults()}
+}
+
+// allow 当前是否可以发送请求
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// success 记录一次成功，清零连续失败次数
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure 记录一次失败，达到阈值时熔断并返回 true
+func (b *circuitBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < b.policy.FailureThreshold {
+		return false
+	}
+	b.openUntil = now.Add(b.policy.Cooldown)
+	return true
+}
+
+// endpoint 客户端可以请求的一个模型（首选或备用）
+type endpoint struct {
+	config   *ModelConfig
+	provider Provider
+	breaker  *circuitBreaker
+}
+
+// newEndpoint 创建模型端点，provider 为 nil 时按 config.Provider 创建
+func newEndpoint(config *ModelConfig, provider Provider) *endpoint {
+	if provider == nil {
+		var err error
+		if provider, err = NewProvider(config); err != nil {
+			provider = unavailableProvider{err: err}
+		}
+	}
+	return &endpoint{
+		config:   config,
+		provider: provider,
+		breaker:  newCircuitBreaker(config.Breaker),
+	}
+}
+
+// canFailover 失败后是否切换到下一个模型：可重试的错误或已熔断
+func canFailover(err error) bool {
+	return IsRetryable(err) || errors.Is(err, ErrCircuitOpen)
+}
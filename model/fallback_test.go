@@ -0,0 +1,161 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubProvider 按设定的状态码返回结果的模型后端，0 表示成功
+type stubProvider struct {
+	mu     sync.Mutex
+	status int
+	calls  int
+}
+
+func (p *stubProvider) Complete(ctx context.Context, req *ChatRequest) (*ModelResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.status != 0 {
+		return nil, &APIError{StatusCode: p.status, Body: http.StatusText(p.status)}
+	}
+	return &ModelResponse{RawContent: "ok"}, nil
+}
+
+func (p *stubProvider) Stream(ctx context.Context, req *ChatRequest, onDelta func(StreamDelta)) (*ModelResponse, error) {
+	return p.Complete(ctx, req)
+}
+
+func (p *stubProvider) SetTransport(rt http.RoundTripper) {}
+
+func (p *stubProvider) set(status int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = status
+}
+
+func (p *stubProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// stubClient 创建首选模型 primary、备用模型 fallback 的客户端
+func stubClient(primary, fallback *stubProvider, maxAttempts int, breaker BreakerPolicy) *Client {
+	retry := RetryPolicy{MaxAttempts: maxAttempts, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	primaryConfig := &ModelConfig{ModelName: "primary", Retry: retry, Breaker: breaker}
+	fallbackConfig := &ModelConfig{ModelName: "fallback", Retry: retry, Breaker: breaker}
+	primaryConfig.Fallbacks = []*ModelConfig{fallbackConfig}
+	return &Client{
+		config:    primaryConfig,
+		endpoints: []*endpoint{newEndpoint(primaryConfig, primary), newEndpoint(fallbackConfig, fallback)},
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int // 首选模型返回的状态码
+		wantModel     string
+		wantStatus    int // 期望返回的错误状态码，0 表示成功
+		wantPrimary   int
+		wantFallbacks int
+	}{
+		{"primary succeeds", 0, "primary", 0, 1, 0},
+		{"5xx fails over after retries", http.StatusServiceUnavailable, "fallback", 0, 2, 1},
+		{"429 fails over after retries", http.StatusTooManyRequests, "fallback", 0, 2, 1},
+		{"400 is returned without failover", http.StatusBadRequest, "", http.StatusBadRequest, 1, 0},
+		{"401 is returned without failover", http.StatusUnauthorized, "", http.StatusUnauthorized, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, fallback := &stubProvider{status: tt.status}, &stubProvider{}
+			client := stubClient(primary, fallback, 2, BreakerPolicy{})
+
+			response, err := client.Request(context.Background(), []Message{CreateUserMessage("hi", "")})
+			if tt.wantStatus != 0 {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus {
+					t.Fatalf("err = %v, want status %d", err, tt.wantStatus)
+				}
+			} else if err != nil {
+				t.Fatalf("Request: %v", err)
+			} else if response.Model != tt.wantModel {
+				t.Errorf("model = %s, want %s", response.Model, tt.wantModel)
+			}
+			if primary.count() != tt.wantPrimary || fallback.count() != tt.wantFallbacks {
+				t.Errorf("calls = primary %d, fallback %d, want %d, %d", primary.count(), fallback.count(), tt.wantPrimary, tt.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	primary, fallback := &stubProvider{status: http.StatusInternalServerError}, &stubProvider{}
+	client := stubClient(primary, fallback, 1, BreakerPolicy{FailureThreshold: 2, Cooldown: 50 * time.Millisecond})
+	request := func() string {
+		t.Helper()
+		response, err := client.Request(context.Background(), []Message{CreateUserMessage("hi", "")})
+		if err != nil {
+			t.Fatalf("Request: %v", err)
+		}
+		return response.Model
+	}
+
+	// 连续失败达到阈值前每次都先尝试首选模型
+	for i := 0; i < 2; i++ {
+		if model := request(); model != "fallback" {
+			t.Fatalf("request %d answered by %s, want fallback", i+1, model)
+		}
+	}
+	if primary.count() != 2 {
+		t.Fatalf("primary calls = %d, want 2", primary.count())
+	}
+
+	// 熔断期间跳过首选模型
+	if model := request(); model != "fallback" {
+		t.Errorf("answered by %s while open, want fallback", model)
+	}
+	if primary.count() != 2 {
+		t.Errorf("primary calls = %d while open, want 2", primary.count())
+	}
+
+	// 冷却结束后试探首选模型，成功则恢复
+	primary.set(0)
+	time.Sleep(60 * time.Millisecond)
+	if model := request(); model != "primary" {
+		t.Errorf("answered by %s after cooldown, want primary", model)
+	}
+	if model := request(); model != "primary" {
+		t.Errorf("answered by %s after recovery, want primary", model)
+	}
+	if primary.count() != 4 || fallback.count() != 3 {
+		t.Errorf("calls = primary %d, fallback %d, want 4, 3", primary.count(), fallback.count())
+	}
+}
+
+func TestClientCircuitBreakerReopens(t *testing.T) {
+	primary, fallback := &stubProvider{status: http.StatusBadGateway}, &stubProvider{}
+	client := stubClient(primary, fallback, 1, BreakerPolicy{FailureThreshold: 2, Cooldown: 20 * time.Millisecond})
+	for i := 0; i < 2; i++ {
+		if _, err := client.Request(context.Background(), nil); err != nil {
+			t.Fatalf("Request: %v", err)
+		}
+	}
+
+	// 半开状态下再次失败立即重新熔断
+	time.Sleep(30 * time.Millisecond)
+	if _, err := client.Request(context.Background(), nil); err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if _, err := client.Request(context.Background(), nil); err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if primary.count() != 3 {
+		t.Errorf("primary calls = %d, want 3 (probe once after cooldown, then open again)", primary.count())
+	}
+}
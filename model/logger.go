@@ -149,6 +149,8 @@ func SetConsoleOnly(only bool) {
 
 // GetLogFile 获取日志文件路径
 func GetLogFile() string {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	if logFile == nil {
 		return ""
 	}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 对话记忆默认参数
+const (
+	defaultMemoryBudget = 6000 // 历史轮次的 token 预算
+	memoryKeepRecent    = 2    // 摘要时保留的最近轮次
+	memoryScreenRunes   = 400  // 历史轮次中屏幕描述保留的最大字数
+	memorySummaryShare  = 4    // 摘要最多占 token 预算的 1/memorySummaryShare
+)
+
+// Turn 一轮决策：当时的屏幕、模型的计划以及执行结果
+type Turn struct {
+	Step    int    // 步骤序号
+	Screen  string // 屏幕描述（已截断）
+	Plan    string // 模型输出的计划
+	Action  string // 计划的操作摘要，如 Tap {"target":"登录"}
+	Outcome string // 执行结果，执行前为空
+}
+
+// ConversationMemory 决策模型的多轮对话记忆，超出 token 预算时把较早的轮次压缩为摘要
+type ConversationMemory struct {
+	budget  int    // token 预算
+	summary string // 较早轮次的摘要
+	turns   []Turn // 尚未压缩的轮次
+}
+
+// NewConversationMemory 创建对话记忆，budget<=0 使用默认预算
+func NewConversationMemory(budget int) *ConversationMemory {
+	if budget <= 0 {
+		budget = defaultMemoryBudget
+	}
+	return &ConversationMemory{budget: budget}
+}
+
+// Reset 清空记忆
+func (m *ConversationMemory) Reset() {
+	m.summary = ""
+	m.turns = nil
+}
+
+// AddTurn 记录一轮决策
+func (m *ConversationMemory) AddTurn(step int, screen string, plan string, action string) {
+	m.turns = append(m.turns, Turn{
+		Step:   step,
+		Screen: truncateRunes(screen, memoryScreenRunes),
+		Plan:   strings.TrimSpace(plan),
+		Action: action,
+	})
+}
+
+// RecordOutcome 记录最近一轮的执行结果
+func (m *ConversationMemory) RecordOutcome(outcome string) {
+	if len(m.turns) == 0 {
+		return
+	}
+	m.turns[len(m.turns)-1].Outcome = outcome
+}
+
+// Messages 构建历史消息：摘要 + 每轮的屏幕/计划/结果，current 为本轮用户消息内容
+func (m *ConversationMemory) Messages(current string) []Message {
+	messages := []Message{}
+	if m.summary != "" {
+		messages = append(messages,
+			CreateUserMessage("之前步骤的摘要：\n"+m.summary, ""),
+			CreateAssistantMessage("好的，我会基于摘要继续完成任务。"),
+		)
+	}
+
+	previousOutcome := ""
+	for _, turn := range m.turns {
+		messages = append(messages,
+			CreateUserMessage(withOutcome(fmt.Sprintf("步骤%d 屏幕:\n%s", turn.Step, turn.Screen), previousOutcome), ""),
+			CreateAssistantMessage(turn.Plan),
+		)
+		previousOutcome = turn.Outcome
+	}
+
+	messages = append(messages, CreateUserMessage(withOutcome(current, previousOutcome), ""))
+	return messages
+}
+
+// withOutcome 在消息前附上上一步的执行结果
+func withOutcome(content string, outcome string) string {
+	if outcome == "" {
+		return content
+	}
+	return fmt.Sprintf("上一步结果: %s\n%s", outcome, content)
+}
+
+// EstimateTokens 估算历史轮次的 token 数
+func (m *ConversationMemory) EstimateTokens() int {
+	total := estimateTokens(m.summary)
+	for _, turn := range m.turns {
+		total += estimateTokens(turn.Screen) + estimateTokens(turn.Plan) + estimateTokens(turn.Outcome)
+	}
+	return total
+}
+
+// Compact 超出预算时调用 summarize 把较早的轮次压缩为摘要，summarize 失败时退化为逐条记录
+//
+// 摘要本身也受预算限制，超出时只保留最近的记录，避免摘要失败后越积越长。
+func (m *ConversationMemory) Compact(ctx context.Context, summarize func(ctx context.Context, transcript string) (string, error)) {
+	if m.EstimateTokens() <= m.budget || len(m.turns) <= memoryKeepRecent {
+		return
+	}
+
+	old := m.turns[:len(m.turns)-memoryKeepRecent]
+	var transcript strings.Builder
+	if m.summary != "" {
+		transcript.WriteString("已有摘要：\n" + m.summary + "\n\n")
+	}
+	for _, turn := range old {
+		transcript.WriteString(fmt.Sprintf("步骤%d\n屏幕: %s\n计划: %s\n结果: %s\n\n", turn.Step, turn.Screen, turn.Plan, turn.Outcome))
+	}
+
+	summary, err := "", fmt.Errorf("no summarizer")
+	if summarize != nil {
+		summary, err = summarize(ctx, transcript.String())
+	}
+	if err != nil || strings.TrimSpace(summary) == "" {
+		if err != nil {
+			LogInfo(fmt.Sprintf("对话摘要失败，改为逐条记录: %v", err))
+		}
+		summary = m.summary
+		for _, turn := range old {
+			summary += fmt.Sprintf("步骤%d: %s → %s\n", turn.Step, turn.Action, turn.Outcome)
+		}
+	}
+
+	m.summary = trimSummary(strings.TrimSpace(summary), m.budget/memorySummaryShare)
+	m.turns = append([]Turn{}, m.turns[len(m.turns)-memoryKeepRecent:]...)
+}
+
+// trimSummary 摘要超过 budget 个 token 时按行保留最近的部分
+func trimSummary(summary string, budget int) string {
+	if estimateTokens(summary) <= budget {
+		return summary
+	}
+	const omitted = "（更早的步骤已省略）"
+	lines := strings.Split(summary, "\n")
+	used := estimateTokens(omitted)
+	kept := []string{}
+	for i := len(lines) - 1; i >= 0; i-- {
+		cost := estimateTokens(lines[i])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		kept = append([]string{lines[i]}, kept...)
+	}
+	if len(kept) == 0 {
+		// 单行就超出预算时截断最后一行
+		return omitted + "\n" + truncateRunes(lines[len(lines)-1], max(budget-used, 0))
+	}
+	return omitted + "\n" + strings.Join(kept, "\n")
+}
+
+// estimateTokens 粗略估算 token 数：中文约 1 字 1 token，ASCII 约 4 字符 1 token
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < 128 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return other + (ascii+3)/4
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fillMemory 记录 n 轮决策，每轮都带执行结果
+func fillMemory(m *ConversationMemory, n int, screen string) {
+	for i := 1; i <= n; i++ {
+		m.AddTurn(i, screen, fmt.Sprintf("计划%d", i), fmt.Sprintf("Tap {\"target\":\"按钮%d\"}", i))
+		m.RecordOutcome(fmt.Sprintf("结果%d", i))
+	}
+}
+
+func TestConversationMemoryMessages(t *testing.T) {
+	m := NewConversationMemory(0)
+	m.summary = "步骤1: 打开应用"
+	m.AddTurn(2, "首页", "计划2", "Tap")
+	m.RecordOutcome("成功")
+	m.AddTurn(3, "搜索页", "计划3", "Type")
+
+	messages := m.Messages("当前屏幕")
+	want := []struct {
+		role    string
+		content string
+	}{
+		{"user", "之前步骤的摘要：\n步骤1: 打开应用"},
+		{"assistant", "好的，我会基于摘要继续完成任务。"},
+		{"user", "步骤2 屏幕:\n首页"},
+		{"assistant", "计划2"},
+		{"user", "上一步结果: 成功\n步骤3 屏幕:\n搜索页"},
+		{"assistant", "计划3"},
+		// 第 3 步尚未记录结果
+		{"user", "当前屏幕"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(messages), len(want), messages)
+	}
+	for i, w := range want {
+		if messages[i].Role != w.role || messageText(messages[i].Content) != w.content {
+			t.Errorf("message %d = %s %q, want %s %q", i, messages[i].Role, messageText(messages[i].Content), w.role, w.content)
+		}
+	}
+}
+
+func TestConversationMemoryOutcomeOnCurrent(t *testing.T) {
+	m := NewConversationMemory(0)
+	if messages := m.Messages("当前屏幕"); len(messages) != 1 || messageText(messages[0].Content) != "当前屏幕" {
+		t.Fatalf("empty memory messages = %+v, want only the current message", messages)
+	}
+
+	// 没有轮次时 RecordOutcome 不生效
+	m.RecordOutcome("忽略")
+	m.AddTurn(1, "首页", "计划1", "Tap")
+	m.RecordOutcome("点击成功")
+
+	messages := m.Messages("当前屏幕")
+	if got := messageText(messages[len(messages)-1].Content); got != "上一步结果: 点击成功\n当前屏幕" {
+		t.Errorf("current message = %q, want the last outcome prefixed", got)
+	}
+	if got := messageText(messages[0].Content); strings.Contains(got, "忽略") {
+		t.Errorf("first message = %q, want outcome recorded before any turn dropped", got)
+	}
+}
+
+func TestConversationMemoryCompact(t *testing.T) {
+	t.Run("under budget", func(t *testing.T) {
+		m := NewConversationMemory(0)
+		fillMemory(m, 5, "首页")
+		m.Compact(context.Background(), func(ctx context.Context, transcript string) (string, error) {
+			t.Fatal("summarize called under budget")
+			return "", nil
+		})
+		if len(m.turns) != 5 || m.summary != "" {
+			t.Errorf("turns = %d, summary = %q, want untouched", len(m.turns), m.summary)
+		}
+	})
+
+	t.Run("summarized", func(t *testing.T) {
+		m := NewConversationMemory(200)
+		fillMemory(m, 5, strings.Repeat("屏", 100))
+		var transcript string
+		m.Compact(context.Background(), func(ctx context.Context, got string) (string, error) {
+			transcript = got
+			return "  已打开应用并搜索  ", nil
+		})
+
+		if m.summary != "已打开应用并搜索" {
+			t.Errorf("summary = %q", m.summary)
+		}
+		if len(m.turns) != memoryKeepRecent || m.turns[0].Step != 4 || m.turns[1].Step != 5 {
+			t.Errorf("turns = %+v, want the last %d kept", m.turns, memoryKeepRecent)
+		}
+		if !strings.Contains(transcript, "步骤3") || strings.Contains(transcript, "步骤4") {
+			t.Errorf("transcript = %q, want only the compacted turns", transcript)
+		}
+	})
+
+	t.Run("fallback is capped", func(t *testing.T) {
+		budget := 200
+		m := NewConversationMemory(budget)
+		failing := func(ctx context.Context, transcript string) (string, error) {
+			return "", errors.New("model unavailable")
+		}
+
+		// 摘要一直失败时，多次压缩后摘要仍不超过预算的份额
+		step := 0
+		for round := 0; round < 10; round++ {
+			for i := 0; i < 5; i++ {
+				step++
+				m.AddTurn(step, strings.Repeat("屏", 100), fmt.Sprintf("计划%d", step), fmt.Sprintf("Tap {\"target\":\"按钮%d\"}", step))
+				m.RecordOutcome(fmt.Sprintf("点击按钮%d成功，页面已跳转", step))
+			}
+			m.Compact(context.Background(), failing)
+			if tokens := estimateTokens(m.summary); tokens > budget/memorySummaryShare {
+				t.Fatalf("round %d: summary uses %d tokens, want at most %d", round, tokens, budget/memorySummaryShare)
+			}
+		}
+		if len(m.turns) != memoryKeepRecent {
+			t.Errorf("turns = %d, want %d", len(m.turns), memoryKeepRecent)
+		}
+		// 保留的是最近的记录
+		if !strings.Contains(m.summary, fmt.Sprintf("步骤%d:", step-memoryKeepRecent)) || strings.Contains(m.summary, "步骤1:") {
+			t.Errorf("summary = %q, want the most recent compacted steps", m.summary)
+		}
+	})
+
+	t.Run("nil summarizer", func(t *testing.T) {
+		m := NewConversationMemory(400)
+		fillMemory(m, 4, strings.Repeat("屏", 150))
+		m.Compact(context.Background(), nil)
+		if !strings.Contains(m.summary, "步骤1:") || !strings.Contains(m.summary, "步骤2:") || len(m.turns) != memoryKeepRecent {
+			t.Errorf("summary = %q, turns = %d, want step lines for the old turns", m.summary, len(m.turns))
+		}
+	})
+}
+
+func TestTrimSummary(t *testing.T) {
+	if got := trimSummary("短摘要", 100); got != "短摘要" {
+		t.Errorf("trimSummary kept = %q, want unchanged", got)
+	}
+
+	got := trimSummary("第一行内容\n第二行内容\n第三行内容", 15)
+	if got != "（更早的步骤已省略）\n第三行内容" {
+		t.Errorf("trimSummary = %q, want only the last line", got)
+	}
+
+	// 单行超出预算时截断该行
+	got = trimSummary(strings.Repeat("长", 100), 20)
+	if !strings.HasPrefix(got, "（更早的步骤已省略）\n") || estimateTokens(got) > 25 {
+		t.Errorf("trimSummary = %q, want the single line truncated", got)
+	}
+}
@@ -0,0 +1,289 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// OpenAIProvider OpenAI 兼容的 /chat/completions 接口，图片以 image_url（data URI）发送
+type OpenAIProvider struct {
+	config     *ModelConfig
+	mu         sync.RWMutex // 保护 httpClient 的替换
+	httpClient *http.Client
+}
+
+// NewOpenAIProvider 创建 OpenAI 兼容接口的后端
+func NewOpenAIProvider(config *ModelConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+// SetTransport 替换底层 HTTP 传输，nil 恢复默认
+//
+// 替换为新的 http.Client，已发出的请求继续使用原来的传输。
+func (p *OpenAIProvider) SetTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.httpClient = &http.Client{Transport: rt}
+}
+
+// ChatCompletionResponse 非流式响应
+type ChatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// openAIUsage 响应中的 usage 字段
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		ImageTokens int `json:"image_tokens"` // 部分后端返回
+	} `json:"prompt_tokens_details"`
+}
+
+// usage 转换为 Usage，u 为 nil 时返回零值
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		ImageTokens:      u.PromptTokensDetails.ImageTokens,
+	}
+}
+
+// Complete 发送非流式请求
+func (p *OpenAIProvider) Complete(ctx context.Context, req *ChatRequest) (*ModelResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request aborted: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response: no choices")
+	}
+
+	message := chatResp.Choices[0].Message
+	response := &ModelResponse{RawContent: message.Content, Usage: chatResp.Usage.usage()}
+	for _, call := range message.ToolCalls {
+		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return response, nil
+}
+
+// Stream 发送流式请求，解析 SSE 的 data 行
+//
+// 请求带 stream_options.include_usage，用量在 [DONE] 之前不含 choices 的数据块中返回。
+func (p *OpenAIProvider) Stream(ctx context.Context, req *ChatRequest, onDelta func(StreamDelta)) (*ModelResponse, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	completed := false // 是否收到 [DONE] 或 finish_reason
+	var rawContent strings.Builder
+	toolCalls := newToolCallAccumulator()
+	var usage Usage
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			completed = true
+			break
+		}
+
+		var streamResp struct {
+			Choices []struct {
+				Delta struct {
+					Content   string          `json:"content"`
+					ToolCalls []toolCallDelta `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			continue
+		}
+		if streamResp.Usage != nil {
+			usage = streamResp.Usage.usage()
+		}
+		if len(streamResp.Choices) == 0 {
+			continue
+		}
+
+		choice := streamResp.Choices[0]
+		if choice.FinishReason != "" {
+			completed = true
+		}
+		for _, delta := range choice.Delta.ToolCalls {
+			toolCalls.add(delta)
+			if onDelta != nil {
+				onDelta(StreamDelta{ToolCall: true})
+			}
+		}
+		if choice.Delta.Content != "" {
+			rawContent.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(StreamDelta{Content: choice.Delta.Content})
+			}
+		}
+	}
+
+	// 流被取消或超时中断
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("request aborted: %w", err)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTruncatedStream, err)
+	}
+	if !completed {
+		return nil, ErrTruncatedStream
+	}
+
+	return &ModelResponse{
+		RawContent: rawContent.String(),
+		ToolCalls:  toolCalls.calls(),
+		Usage:      usage,
+	}, nil
+}
+
+// send 构建并发送请求，非 200 响应返回 APIError
+func (p *OpenAIProvider) send(ctx context.Context, req *ChatRequest, stream bool) (*http.Response, error) {
+	messages := []Message{}
+	if req.System != nil {
+		messages = append(messages, *req.System)
+	}
+	messages = append(messages, req.Messages...)
+
+	chatReq := &ChatCompletionRequest{
+		Messages:         messages,
+		Model:            p.config.ModelName,
+		MaxTokens:        p.config.MaxTokens,
+		Temperature:      p.config.Temperature,
+		TopP:             p.config.TopP,
+		FrequencyPenalty: p.config.FrequencyPenalty,
+		Stream:           stream,
+	}
+	if stream {
+		chatReq.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	if req.Options != nil {
+		chatReq.Tools = req.Options.Tools
+		chatReq.ToolChoice = req.Options.ToolChoice
+		chatReq.ResponseFormat = req.Options.ResponseFormat
+	}
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+
+	p.mu.RLock()
+	httpClient := p.httpClient
+	p.mu.RUnlock()
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+// toolCallDelta 流式响应中的函数调用片段
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolCallAccumulator 按 index 拼接流式返回的函数调用
+type toolCallAccumulator struct {
+	order []int
+	byIdx map[int]*ToolCall
+}
+
+func newToolCallAccumulator() *toolCallAccumulator {
+	return &toolCallAccumulator{byIdx: map[int]*ToolCall{}}
+}
+
+// add 合并一个函数调用片段
+func (t *toolCallAccumulator) add(delta toolCallDelta) {
+	call, ok := t.byIdx[delta.Index]
+	if !ok {
+		call = &ToolCall{}
+		t.byIdx[delta.Index] = call
+		t.order = append(t.order, delta.Index)
+	}
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	call.Name += delta.Function.Name
+	call.Arguments += delta.Function.Arguments
+}
+
+// calls 返回拼接完成的函数调用
+func (t *toolCallAccumulator) calls() []ToolCall {
+	if len(t.order) == 0 {
+		return nil
+	}
+	result := make([]ToolCall, 0, len(t.order))
+	for _, idx := range t.order {
+		result = append(result, *t.byIdx[idx])
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PlanSchema PlanResult 的 JSON Schema，用于 response_format: json_schema
+func PlanSchema() map[string]interface{} {
+	names := make([]string, 0, len(DecisionTools()))
+	for _, tool := range DecisionTools() {
+		names = append(names, tool.Function.Name)
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"thought": map[string]interface{}{
+				"type":        "string",
+				"description": "思考过程",
+			},
+			"action_type": map[string]interface{}{
+				"type": "string",
+				"enum": names,
+			},
+			"parameters": map[string]interface{}{
+				"type":        "object",
+				"description": "操作参数，如 {\"app\":\"微信\"}、{\"selector\":{\"text\":\"登录\"}}、{\"message\":\"结果\"}",
+			},
+			"reason": map[string]interface{}{
+				"type":        "string",
+				"description": "给视觉模型的明确指令，finish 时为任务结果",
+			},
+		},
+		"required":             []string{"thought", "action_type", "parameters", "reason"},
+		"additionalProperties": false,
+	}
+}
+
+// planResponseFormat 决策模型 json_schema 模式的 response_format
+func planResponseFormat() *ResponseFormat {
+	return &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchemaFormat{
+			Name:   "plan",
+			Schema: PlanSchema(),
+		},
+	}
+}
+
+// planJSON 与 PlanSchema 一一对应的 JSON 计划，PlanResult 的其他字段（如 finished）不允许出现在模型输出中
+type planJSON struct {
+	Thought    string                 `json:"thought"`
+	ActionType string                 `json:"action_type"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Reason     string                 `json:"reason"`
+}
+
+// decodePlanJSON 严格解码 JSON 计划：不允许未知字段，操作类型和必填参数需符合工具定义
+func decodePlanJSON(content string, tools []Tool) (*PlanResult, error) {
+	data := strings.TrimSpace(content)
+	// 部分后端会把 JSON 包在 ```json 代码块中
+	data = strings.TrimPrefix(data, "```json")
+	data = strings.TrimPrefix(data, "```")
+	data = strings.TrimSuffix(data, "```")
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, fmt.Errorf("empty plan")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.DisallowUnknownFields()
+
+	var decoded planJSON
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("invalid plan JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid plan JSON: unexpected data after plan")
+	}
+
+	plan := PlanResult{
+		ActionType: decoded.ActionType,
+		Parameters: decoded.Parameters,
+		Reason:     decoded.Reason,
+		Thought:    decoded.Thought,
+	}
+
+	if plan.ActionType == "" {
+		return nil, fmt.Errorf("action_type is required")
+	}
+	if plan.Parameters == nil {
+		plan.Parameters = map[string]interface{}{}
+	}
+
+	var tool *Tool
+	for i := range tools {
+		if tools[i].Function.Name == plan.ActionType {
+			tool = &tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, fmt.Errorf("unknown action_type: %s", plan.ActionType)
+	}
+
+	// 工具定义中 reason 为必填参数，校验时一并带上
+	args := map[string]interface{}{"reason": plan.Reason}
+	for k, v := range plan.Parameters {
+		args[k] = v
+	}
+	if err := validateToolArguments(tool, args); err != nil {
+		return nil, err
+	}
+
+	plan.Finished = plan.ActionType == "finish"
+	if plan.Finished {
+		if message, ok := plan.Parameters["message"].(string); ok && message != "" {
+			plan.Reason = message
+		}
+	}
+
+	return &plan, nil
+}
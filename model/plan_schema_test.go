@@ -0,0 +1,52 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodePlanJSON(t *testing.T) {
+	plan, err := decodePlanJSON("```json\n"+`{"thought":"已发送","action_type":"finish","parameters":{"message":"消息已发送"},"reason":"完成"}`+"\n```", DecisionTools())
+	if err != nil {
+		t.Fatalf("decodePlanJSON: %v", err)
+	}
+	if !plan.Finished || plan.Reason != "消息已发送" || plan.Thought != "已发送" {
+		t.Errorf("plan = %+v, want finished with message as reason", plan)
+	}
+
+	plan, err = decodePlanJSON(`{"thought":"","action_type":"Back","parameters":{},"reason":"返回"}`, DecisionTools())
+	if err != nil {
+		t.Fatalf("decodePlanJSON: %v", err)
+	}
+	if plan.Finished {
+		t.Error("Back plan marked finished")
+	}
+}
+
+func TestDecodePlanJSONRejectsInvalidPlans(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		// finished 由 action_type 推导，模型不能自行声明
+		{"finished field", `{"thought":"","action_type":"Back","parameters":{},"reason":"返回","finished":true}`, "unknown field \"finished\""},
+		{"unknown field", `{"thought":"","action_type":"Back","parameters":{},"reason":"返回","confidence":0.9}`, "unknown field \"confidence\""},
+		{"trailing data", `{"thought":"","action_type":"Back","parameters":{},"reason":"返回"} {}`, "unexpected data"},
+		{"missing action_type", `{"thought":"","parameters":{},"reason":"返回"}`, "action_type is required"},
+		{"unknown action", `{"thought":"","action_type":"Fly","parameters":{},"reason":"起飞"}`, "unknown action_type"},
+		{"missing required argument", `{"thought":"","action_type":"Launch","parameters":{},"reason":"打开应用"}`, "missing required argument \"app\""},
+		{"empty", "```json\n```", "empty plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := decodePlanJSON(tt.content, DecisionTools())
+			if err == nil {
+				t.Fatalf("decodePlanJSON = %+v, want error", plan)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
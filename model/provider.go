@@ -0,0 +1,70 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// 模型后端
+const (
+	ProviderOpenAI    = "openai"    // OpenAI 兼容的 /chat/completions 接口（DeepSeek、智谱等）
+	ProviderAnthropic = "anthropic" // Anthropic Messages 接口（/messages）
+)
+
+// ChatRequest 一次对话请求
+type ChatRequest struct {
+	System   *Message        // 系统提示词，nil 表示不发送
+	Messages []Message       // 对话消息，用户消息可包含图片（见 CreateImageMessage）
+	Options  *RequestOptions // 附加参数（tools、response_format），可为 nil
+}
+
+// StreamDelta 流式响应的一个增量
+type StreamDelta struct {
+	Content  string // 文本增量
+	ToolCall bool   // 是否为函数调用片段
+}
+
+// Provider 模型后端，负责把对话请求转换为具体 API 的 HTTP 请求并解析响应
+//
+// Provider 只发送单次请求；系统提示词、超时、重试和响应解析由 Client 统一处理。
+// 返回的 ModelResponse 只需填写 RawContent 和 ToolCalls。
+type Provider interface {
+	// Complete 非流式请求，返回完整响应
+	Complete(ctx context.Context, req *ChatRequest) (*ModelResponse, error)
+	// Stream 流式请求，每收到一段增量调用 onDelta（可为 nil），结束后返回拼接的完整响应；
+	// 流在完成前中断时返回 ErrTruncatedStream
+	Stream(ctx context.Context, req *ChatRequest, onDelta func(StreamDelta)) (*ModelResponse, error)
+	// SetTransport 替换底层 HTTP 传输（用于录制/回放模型响应），nil 恢复默认
+	SetTransport(rt http.RoundTripper)
+}
+
+// NewProvider 按 ModelConfig.Provider 创建模型后端，为空时使用 OpenAI 兼容接口
+func NewProvider(config *ModelConfig) (Provider, error) {
+	switch config.Provider {
+	case "", ProviderOpenAI:
+		return NewOpenAIProvider(config), nil
+	case ProviderAnthropic:
+		return NewAnthropicProvider(config), nil
+	default:
+		return nil, fmt.Errorf("unknown model provider: %s", config.Provider)
+	}
+}
+
+// unavailableProvider 创建失败的后端，每次请求都返回创建时的错误
+type unavailableProvider struct {
+	err error
+}
+
+// Complete 返回创建错误
+func (p unavailableProvider) Complete(ctx context.Context, req *ChatRequest) (*ModelResponse, error) {
+	return nil, p.err
+}
+
+// Stream 返回创建错误
+func (p unavailableProvider) Stream(ctx context.Context, req *ChatRequest, onDelta func(StreamDelta)) (*ModelResponse, error) {
+	return nil, p.err
+}
+
+// SetTransport 无操作
+func (p unavailableProvider) SetTransport(rt http.RoundTripper) {}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 模型请求重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数（含首次），1 表示不重试，0 使用默认值
+	InitialBackoff time.Duration // 第一次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待上限
+	Multiplier     float64       // 每次重试等待时间的倍数
+	Jitter         float64       // 随机抖动比例（0-1），避免多个请求同时重试
+}
+
+// DefaultRetryPolicy 返回默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2.0,
+		Jitter:         0.2,
+	}
+}
+
+// withDefaults 用默认值补全未设置的字段
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	return p
+}
+
+// Backoff 计算第 attempt 次失败后的等待时间，服务端返回 Retry-After 时按它等待
+//
+// Retry-After 不受 MaxBackoff 限制；超过 MaxBackoff 时 withRetry 不再重试，而是返回错误交给故障转移。
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	if wait := retryAfter(err); wait > 0 {
+		return wait
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+// APIError 模型 API 返回的非 200 响应
+type APIError struct {
+	StatusCode int           // HTTP 状态码
+	Body       string        // 响应内容
+	RetryAfter time.Duration // Retry-After 头指定的等待时间
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: status=%d, body=%s", e.StatusCode, e.Body)
+}
+
+// newAPIError 从 HTTP 响应构建 APIError
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// retryAfter 返回错误中服务端要求的等待时间，没有时返回 0
+func retryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析 Retry-After 头（秒数或 HTTP 日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ErrTruncatedStream 流式响应在收到结束标记前中断
+var ErrTruncatedStream = errors.New("stream ended before completion")
+
+// IsRetryable 判断错误是否可以重试
+//
+// 可重试：429、408、5xx、连接重置/拒绝、网络超时、单次请求超时、流被截断；
+// 不可重试：400、401、403、404 等客户端错误，无法连接的地址（协议、主机名、证书错误），以及调用方主动取消。
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode >= 500:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, ErrTruncatedStream) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	// 只有网络超时可以重试；*url.Error 也实现了 net.Error，不支持的协议、无效主机名、证书错误等不应重试
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// withRetry 按重试策略执行请求，ctx 取消时立即返回；Retry-After 超过 MaxBackoff 时不再重试
+func withRetry(ctx context.Context, policy RetryPolicy, name string, do func() (*ModelResponse, error)) (*ModelResponse, error) {
+	policy = policy.withDefaults()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := do()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		if attempt >= policy.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			break
+		}
+		// 服务端要求的等待超过上限时提前重试只会再次失败，直接返回
+		if wait := retryAfter(err); wait > policy.MaxBackoff {
+			LogInfo(fmt.Sprintf("%s 要求 %v 后重试，超过等待上限 %v，不再重试: %v", name, wait.Round(time.Second), policy.MaxBackoff, err))
+			break
+		}
+
+		wait := policy.Backoff(attempt, err)
+		LogInfo(fmt.Sprintf("%s 请求失败（第 %d/%d 次），%v 后重试: %v", name, attempt, policy.MaxAttempts, wait.Round(time.Millisecond), err))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request aborted while waiting to retry: %w (last error: %v)", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+
+	return nil, lastErr
+}
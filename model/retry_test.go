@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError 实现 net.Error 的超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"408", &APIError{StatusCode: http.StatusRequestTimeout}, true},
+		{"500", &APIError{StatusCode: http.StatusInternalServerError}, true},
+		{"503 wrapped", fmt.Errorf("decision: %w", &APIError{StatusCode: http.StatusServiceUnavailable}), true},
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, false},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"403", &APIError{StatusCode: http.StatusForbidden}, false},
+		{"404", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"connection reset", &url.Error{Op: "Post", URL: "https://api.test", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+		{"connection refused", &url.Error{Op: "Post", URL: "https://api.test", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{"truncated stream", fmt.Errorf("failed to read stream: %w", ErrTruncatedStream), true},
+		{"unexpected EOF", fmt.Errorf("failed to read response: %w", io.ErrUnexpectedEOF), true},
+		{"decode error", fmt.Errorf("failed to decode response: %w", errors.New("invalid character")), false},
+		{"network timeout", &url.Error{Op: "Post", URL: "https://api.test", Err: timeoutError{}}, true},
+		{"unsupported protocol", &url.Error{Op: "Post", URL: "ftp://api.test", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"no such host", &url.Error{Op: "Post", URL: "https://api.invalid", Err: &net.DNSError{Err: "no such host", Name: "api.invalid", IsNotFound: true}}, false},
+		{"request timeout", fmt.Errorf("request aborted: %w", context.DeadlineExceeded), true},
+		{"cancelled", fmt.Errorf("request aborted: %w", context.Canceled), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"5", 5 * time.Second, 5 * time.Second},
+		{"0", 0, 0},
+		{"-3", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v-%v", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	tests := []struct {
+		name    string
+		attempt int
+		err     error
+		want    time.Duration
+	}{
+		{"first retry", 1, &APIError{StatusCode: 503}, time.Second},
+		{"exponential", 3, &APIError{StatusCode: 503}, 4 * time.Second},
+		{"capped", 4, &APIError{StatusCode: 503}, 5 * time.Second},
+		{"retry-after", 1, &APIError{StatusCode: 429, RetryAfter: 3 * time.Second}, 3 * time.Second},
+		// Retry-After 不受 MaxBackoff 限制，是否重试由 withRetry 决定
+		{"retry-after above max", 1, &APIError{StatusCode: 429, RetryAfter: time.Minute}, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Backoff(tt.attempt, tt.err); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	tests := []struct {
+		name      string
+		errs      []error // 依次返回的错误，用完后成功
+		wantCalls int
+		wantErr   bool
+		minWait   time.Duration
+	}{
+		{"success", nil, 1, false, 0},
+		{"503 then success", []error{&APIError{StatusCode: 503}}, 2, false, 0},
+		{"attempts exhausted", []error{&APIError{StatusCode: 503}, &APIError{StatusCode: 502}, &APIError{StatusCode: 500}}, 3, true, 0},
+		{"400 is not retried", []error{&APIError{StatusCode: 400}}, 1, true, 0},
+		{"401 is not retried", []error{&APIError{StatusCode: 401}}, 1, true, 0},
+		{"truncated stream", []error{ErrTruncatedStream}, 2, false, 0},
+		{"retry-after honoured", []error{&APIError{StatusCode: 429, RetryAfter: 30 * time.Millisecond}}, 2, false, 30 * time.Millisecond},
+		{"retry-after above max backoff", []error{&APIError{StatusCode: 429, RetryAfter: time.Minute}}, 1, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			start := time.Now()
+			_, err := withRetry(context.Background(), policy, "test", func() (*ModelResponse, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return &ModelResponse{}, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait || elapsed > 5*time.Second {
+				t.Errorf("elapsed = %v, want at least %v", elapsed, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestWithRetryCancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Second}
+
+	calls := 0
+	_, err := withRetry(ctx, policy, "test", func() (*ModelResponse, error) {
+		calls++
+		return nil, &APIError{StatusCode: 503}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want ctx error after 1 call", err, calls)
+	}
+}
@@ -1,42 +1,130 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 )
 
 // DecisionModel 决策模型，负责任务规划和逻辑处理
+//
+// 对话记忆属于单个任务，每个 PhoneAgent 使用独立的 DecisionModel；方法之间互斥，并发调用是安全的。
 type DecisionModel struct {
-	client *Client // 复用 AI API 客户端
+	mu         sync.Mutex          // 保护对话记忆
+	client     *Client             // 复用 AI API 客户端
+	outputMode string              // 输出模式：tags、tools 或 json_schema
+	tools      []Tool              // 操作工具定义（tools/json_schema 模式用于参数校验）
+	memory     *ConversationMemory // 多轮对话记忆
 }
 
+// maxPlanAttempts 结构化输出校验失败时的最大尝试次数（含首次）
+const maxPlanAttempts = 3
+
 // NewDecisionModel 创建决策模型
 func NewDecisionModel(config *ModelConfig) *DecisionModel {
+	memory := NewConversationMemory(config.MemoryBudget)
+
+	switch config.OutputMode {
+	case OutputModeTools:
+		return &DecisionModel{
+			client:     NewClientWithSystemPrompt(config, DecisionToolPrompt),
+			outputMode: OutputModeTools,
+			tools:      DecisionTools(),
+			memory:     memory,
+		}
+	case OutputModeJSONSchema:
+		return &DecisionModel{
+			client:     NewClientWithSystemPrompt(config, DecisionJSONPrompt),
+			outputMode: OutputModeJSONSchema,
+			tools:      DecisionTools(),
+			memory:     memory,
+		}
+	}
+
 	client := NewClientWithSystemPrompt(config, DecisionModelPrompt)
 	return &DecisionModel{
-		client: client,
+		client:     client,
+		outputMode: OutputModeTags,
+		memory:     memory,
+	}
+}
+
+// SetUsageMeter 设置用量统计，决策和对话摘要的调用都记为 RoleDecision
+func (m *DecisionModel) SetUsageMeter(meter *UsageMeter) {
+	m.client.SetUsageMeter(meter, RoleDecision)
+}
+
+// SetTransport 替换决策模型客户端的 HTTP 传输（用于录制/回放模型响应）
+func (m *DecisionModel) SetTransport(rt http.RoundTripper) {
+	m.client.SetTransport(rt)
+}
+
+// Reset 清空对话记忆，开始新任务前调用
+func (m *DecisionModel) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memory.Reset()
+}
+
+// RecordOutcome 记录上一步计划的执行结果，下一轮会随屏幕一起发给决策模型
+func (m *DecisionModel) RecordOutcome(success bool, message string) {
+	outcome := "成功"
+	if !success {
+		outcome = "失败"
+	}
+	if message != "" {
+		outcome += ": " + message
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memory.RecordOutcome(outcome)
 }
 
 // PlanStep 计划下一步操作
-func (m *DecisionModel) PlanStep(task string, screenInfo string, currentStep int, maxSteps int, history []ActionHistory) (*PlanResult, error) {
-	// 构建任务上下文
+func (m *DecisionModel) PlanStep(ctx context.Context, task string, screenInfo string, currentStep int, maxSteps int, history []ActionHistory) (*PlanResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 历史超出 token 预算时，先把较早的轮次压缩为摘要
+	m.memory.Compact(ctx, m.summarize)
+
+	// 构建任务上下文，历史轮次（屏幕、计划、执行结果）作为多轮对话发送
 	taskContext := m.buildTaskContext(task, screenInfo, currentStep, maxSteps, history)
-	messages := []Message{
-		CreateUserMessage(taskContext, ""),
-	}
+	messages := m.memory.Messages(taskContext)
 
 	// 记录日志（包含系统提示词）
 	LogStart("决策模型提示词")
-	LogContent(*m.client.SystemPrompt)
-	LogContent(messages[0])
+	LogContent(*m.client.systemPrompt())
+	LogInfo(fmt.Sprintf("历史消息: %d 条，约 %d tokens", len(messages)-1, m.memory.EstimateTokens()))
+	LogContent(messages[len(messages)-1])
 	LogEnd("决策模型提示词")
 
+	var plan *PlanResult
+	var transcript string
+	var err error
+	if m.outputMode == OutputModeTools || m.outputMode == OutputModeJSONSchema {
+		plan, transcript, err = m.planStructured(ctx, messages)
+	} else {
+		plan, transcript, err = m.planTags(ctx, messages)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Raw = transcript
+	m.memory.AddTurn(currentStep, screenInfo, transcript, planSummary(plan))
+	return plan, nil
+}
+
+// planTags tags 模式下请求计划，返回计划和模型原始输出
+func (m *DecisionModel) planTags(ctx context.Context, messages []Message) (*PlanResult, string, error) {
 	// 调用决策模型（系统提示词已缓存在client中）
-	response, err := m.client.Request(messages)
+	response, err := m.client.Request(ctx, messages)
 	if err != nil {
-		return nil, fmt.Errorf("Decision model error: %w", err)
+		return nil, "", fmt.Errorf("Decision model error: %w", err)
 	}
 
 	LogStart("决策模型输出")
@@ -45,7 +133,87 @@ func (m *DecisionModel) PlanStep(task string, screenInfo string, currentStep int
 
 	// 直接从流式响应的RawContent解析计划
 	plan := m.parsePlan(response.RawContent)
-	return plan, nil
+	return plan, response.RawContent, nil
+}
+
+// summarize 让决策模型把较早的轮次压缩为摘要
+func (m *DecisionModel) summarize(ctx context.Context, transcript string) (string, error) {
+	system := CreateSystemMessage(MemorySummaryPrompt)
+	response, err := m.client.RequestWithSystem(ctx, []Message{CreateUserMessage(transcript, "")}, &system)
+	if err != nil {
+		return "", err
+	}
+
+	LogStart("对话摘要")
+	LogContent(response.RawContent)
+	LogEnd("对话摘要")
+
+	return strings.TrimSpace(response.RawContent), nil
+}
+
+// planSummary 计划的一行摘要，用于对话摘要失败时的逐条记录
+func planSummary(plan *PlanResult) string {
+	if len(plan.Parameters) == 0 {
+		return plan.ActionType
+	}
+	params, _ := json.Marshal(plan.Parameters)
+	return plan.ActionType + " " + string(params)
+}
+
+// planStructured tools/json_schema 模式下请求计划，校验失败时把错误反馈给模型重新输出
+func (m *DecisionModel) planStructured(ctx context.Context, messages []Message) (*PlanResult, string, error) {
+	opts := &RequestOptions{}
+	if m.outputMode == OutputModeTools {
+		opts.Tools = m.tools
+		opts.ToolChoice = "auto"
+	} else {
+		opts.ResponseFormat = planResponseFormat()
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxPlanAttempts; attempt++ {
+		response, err := m.client.RequestWithOptions(ctx, messages, m.client.systemPrompt(), opts)
+		if err != nil {
+			return nil, "", fmt.Errorf("Decision model error: %w", err)
+		}
+
+		LogStart("决策模型输出")
+		LogContent(response)
+		LogEnd("决策模型输出")
+
+		plan, err := m.decodeStructured(response)
+		if err == nil {
+			return plan, structuredTranscript(response), nil
+		}
+		lastErr = err
+
+		LogInfo(fmt.Sprintf("决策模型输出无效（第 %d/%d 次）: %v", attempt, maxPlanAttempts, err))
+		if response.RawContent != "" {
+			messages = append(messages, CreateAssistantMessage(response.RawContent))
+		}
+		messages = append(messages, CreateUserMessage(fmt.Sprintf("上一次输出无效：%v\n请修正后重新输出。", err), ""))
+	}
+
+	return nil, "", fmt.Errorf("Decision model error: invalid plan after %d attempts: %w", maxPlanAttempts, lastErr)
+}
+
+// structuredTranscript 结构化输出的文本形式，函数调用记为 Name(arguments)
+func structuredTranscript(response *ModelResponse) string {
+	if len(response.ToolCalls) == 0 {
+		return response.RawContent
+	}
+	call := response.ToolCalls[0]
+	return strings.TrimSpace(response.RawContent + "\n" + fmt.Sprintf("%s(%s)", call.Name, call.Arguments))
+}
+
+// decodeStructured 按输出模式解析并校验计划
+func (m *DecisionModel) decodeStructured(response *ModelResponse) (*PlanResult, error) {
+	if m.outputMode == OutputModeJSONSchema {
+		return decodePlanJSON(response.RawContent, m.tools)
+	}
+
+	// 模型未调用函数时（即使按标签格式输出）也视为无效，与 json_schema 模式一样要求重新输出
+	return planFromToolCalls(response.ToolCalls, m.tools, response.RawContent)
 }
 
 // buildTaskContext 构建任务上下文（优化：只保留最近5条历史）
@@ -62,7 +230,11 @@ func (m *DecisionModel) buildTaskContext(task string, screenInfo string, current
 		}
 		context += "历史: "
 		for _, h := range recent {
-			context += fmt.Sprintf("%s→", h.Action)
+			if h.Note != "" {
+				context += fmt.Sprintf("%s(%s)→", h.Action, h.Note)
+			} else {
+				context += fmt.Sprintf("%s→", h.Action)
+			}
 		}
 		context += "\n"
 	}
@@ -136,11 +308,12 @@ func (m *DecisionModel) parsePlan(content string) *PlanResult {
 
 // PlanResult 决策模型计划结果
 type PlanResult struct {
-	ActionType string                 // 操作类型
-	Parameters map[string]interface{} // 操作参数
-	Reason     string                 // 操作原因
-	Thought    string                 // 思考过程
-	Finished   bool                   // 是否完成
+	ActionType string                 `json:"action_type"` // 操作类型
+	Parameters map[string]interface{} `json:"parameters"`  // 操作参数
+	Reason     string                 `json:"reason"`      // 操作原因
+	Thought    string                 `json:"thought"`     // 思考过程
+	Finished   bool                   `json:"finished"`    // 是否完成
+	Raw        string                 `json:"-"`           // 模型原始输出
 }
 
 // ActionHistory 操作历史记录
@@ -148,4 +321,5 @@ type ActionHistory struct {
 	Action  string // 操作类型
 	Reason  string // 操作原因
 	Success bool   // 是否成功
+	Note    string // 执行校验备注，如 "no visible change"
 }
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeStructuredToolsRequiresToolCall(t *testing.T) {
+	m := NewDecisionModel(&ModelConfig{ModelName: "test", OutputMode: OutputModeTools})
+
+	// 标签格式的文本里出现 finish 不能被当成完成
+	response := &ModelResponse{RawContent: "<thought>还没完成，稍后 finish</thought><action>Tap</action><parameters>{}</parameters>"}
+	plan, err := m.decodeStructured(response)
+	if err == nil {
+		t.Fatalf("decodeStructured = %+v, want no tool call error", plan)
+	}
+	if !strings.Contains(err.Error(), "no tool call") {
+		t.Errorf("err = %v, want no tool call error", err)
+	}
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// selectorSchema 界面元素选择器参数
+var selectorSchema = map[string]interface{}{
+	"type":        "object",
+	"description": "界面元素选择器，屏幕描述包含界面元素列表时优先使用",
+	"properties": map[string]interface{}{
+		"text":         map[string]interface{}{"type": "string", "description": "元素文字"},
+		"resource_id":  map[string]interface{}{"type": "string", "description": "元素 id"},
+		"content_desc": map[string]interface{}{"type": "string", "description": "元素描述"},
+		"index":        map[string]interface{}{"type": "integer", "description": "界面元素列表中的[序号]"},
+	},
+}
+
+// reasonSchema 所有操作共用的 reason 参数
+var reasonSchema = map[string]interface{}{
+	"type":        "string",
+	"description": "给视觉模型的明确指令，需坐标的操作必须写明要定位的目标",
+}
+
+// newTool 构建一个函数工具定义，reason 参数自动加入且必填
+func newTool(name, description string, properties map[string]interface{}, required ...string) Tool {
+	props := map[string]interface{}{"reason": reasonSchema}
+	for k, v := range properties {
+		props[k] = v
+	}
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": props,
+				"required":   append([]string{"reason"}, required...),
+			},
+		},
+	}
+}
+
+// pointerProperties 点击类操作的参数
+func pointerProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"target":   map[string]interface{}{"type": "string", "description": "目标元素的文字描述"},
+		"selector": selectorSchema,
+	}
+}
+
+// DecisionTools 决策模型可调用的操作工具（每个操作对应一个函数）
+func DecisionTools() []Tool {
+	return []Tool{
+		newTool("Launch", "启动应用", map[string]interface{}{
+			"app": map[string]interface{}{"type": "string", "description": "应用名称"},
+		}, "app"),
+		newTool("Tap", "点击屏幕元素", pointerProperties()),
+		newTool("DoubleTap", "双击屏幕元素", pointerProperties()),
+		newTool("LongPress", "长按屏幕元素", pointerProperties()),
+		newTool("Swipe", "滑动屏幕", map[string]interface{}{
+			"direction": map[string]interface{}{"type": "string", "enum": []string{"up", "down", "left", "right"}, "description": "手指移动方向，up 为从下往上滑"},
+		}, "direction"),
+		newTool("Type", "在当前输入框输入文本", map[string]interface{}{
+			"text": map[string]interface{}{"type": "string", "description": "要输入的文本"},
+		}, "text"),
+		newTool("Back", "返回上一页", nil),
+		newTool("Home", "回到桌面", nil),
+		newTool("Wait", "等待页面加载", map[string]interface{}{
+			"duration": map[string]interface{}{"type": "number", "description": "等待秒数"},
+		}),
+		newTool("Take_over", "请求人工接管（登录、验证码等）", map[string]interface{}{
+			"message": map[string]interface{}{"type": "string", "description": "提示用户的内容"},
+		}, "message"),
+		newTool("finish", "任务已完成", map[string]interface{}{
+			"message": map[string]interface{}{"type": "string", "description": "任务结果"},
+		}, "message"),
+	}
+}
+
+// planFromToolCalls 根据模型返回的第一个函数调用构建计划
+func planFromToolCalls(calls []ToolCall, tools []Tool, content string) (*PlanResult, error) {
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("decision model returned no tool call, the plan must be returned by calling one of the tools")
+	}
+	call := calls[0]
+
+	var tool *Tool
+	for i := range tools {
+		if tools[i].Function.Name == call.Name {
+			tool = &tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, fmt.Errorf("unknown tool: %s", call.Name)
+	}
+
+	params := map[string]interface{}{}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &params); err != nil {
+			return nil, fmt.Errorf("invalid arguments for %s: %w", call.Name, err)
+		}
+	}
+	if err := validateToolArguments(tool, params); err != nil {
+		return nil, err
+	}
+
+	plan := &PlanResult{
+		ActionType: call.Name,
+		Parameters: params,
+		Thought:    strings.TrimSpace(content),
+		Finished:   call.Name == "finish",
+	}
+	if reason, ok := params["reason"].(string); ok {
+		plan.Reason = reason
+		delete(params, "reason")
+	}
+	// finish 的结果放在 message 中，agent 以 Reason 作为完成信息
+	if plan.Finished {
+		if message, ok := params["message"].(string); ok && message != "" {
+			plan.Reason = message
+		}
+	}
+
+	return plan, nil
+}
+
+// validateToolArguments 检查必填参数和基本类型
+func validateToolArguments(tool *Tool, params map[string]interface{}) error {
+	schema := tool.Function.Parameters
+	properties, _ := schema["properties"].(map[string]interface{})
+	required, _ := schema["required"].([]string)
+
+	for _, name := range required {
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("%s: missing required argument %q", tool.Function.Name, name)
+		}
+	}
+
+	for name, value := range params {
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if !matchesJSONType(value, prop["type"]) {
+			return fmt.Errorf("%s: argument %q should be %v", tool.Function.Name, name, prop["type"])
+		}
+		if enum, ok := prop["enum"].([]string); ok {
+			s, _ := value.(string)
+			found := false
+			for _, e := range enum {
+				if e == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: argument %q must be one of %s", tool.Function.Name, name, strings.Join(enum, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// matchesJSONType 判断 JSON 解码后的值是否符合 schema 类型
+func matchesJSONType(value interface{}, typ interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	default:
+		return true
+	}
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"sort"
+	"sync"
+)
+
+// 模型角色，用于按角色统计用量
+const (
+	RoleDecision   = "decision"   // 决策（含对话摘要）
+	RoleVision     = "vision"     // 屏幕分析
+	RoleCoordinate = "coordinate" // 坐标识别
+)
+
+// Usage 一次模型调用的 token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ImageTokens      int `json:"image_tokens,omitempty"` // 图片 token（已包含在 PromptTokens 中），后端未返回时为 0
+}
+
+// Add 累加用量
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.ImageTokens += other.ImageTokens
+}
+
+// Total 输入与输出 token 之和
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Price 模型单价（每百万 token），币种由配置统一指定
+type Price struct {
+	Input  float64 // 输入 token
+	Output float64 // 输出 token
+	Image  float64 // 图片 token，为 0 时按输入单价计
+}
+
+// Cost 计算用量对应的费用，price 为 nil 时为 0
+func (p *Price) Cost(u Usage) float64 {
+	if p == nil {
+		return 0
+	}
+	input := float64(u.PromptTokens) * p.Input
+	if p.Image > 0 {
+		input += float64(u.ImageTokens) * (p.Image - p.Input)
+	}
+	return (input + float64(u.CompletionTokens)*p.Output) / 1e6
+}
+
+// UsageStats 一组模型调用的累计用量与费用
+type UsageStats struct {
+	Calls int `json:"calls"`
+	Usage
+	Cost      float64        `json:"cost"`
+	CacheHits int            `json:"cache_hits,omitempty"` // 由视觉缓存直接返回、未调用模型的次数
+	Models    map[string]int `json:"models,omitempty"`     // 实际应答的模型及其调用次数（含故障转移后的备用模型）
+}
+
+// add 累加一组统计
+func (s *UsageStats) add(other UsageStats) {
+	s.Calls += other.Calls
+	s.Usage.Add(other.Usage)
+	s.Cost += other.Cost
+	s.CacheHits += other.CacheHits
+	if len(other.Models) > 0 {
+		// 复制后再累加，汇总按值传递，不能修改可能共享的 map
+		models := make(map[string]int, len(s.Models)+len(other.Models))
+		for name, calls := range s.Models {
+			models[name] = calls
+		}
+		for name, calls := range other.Models {
+			models[name] += calls
+		}
+		s.Models = models
+	}
+}
+
+// ModelNames 按名称排序的应答模型列表
+func (s UsageStats) ModelNames() []string {
+	names := make([]string, 0, len(s.Models))
+	for name := range s.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UsageSummary 按角色汇总的用量，内嵌的 UsageStats 为所有角色合计
+type UsageSummary struct {
+	UsageStats
+	Roles map[string]UsageStats `json:"roles,omitempty"` // 键为 RoleDecision、RoleVision、RoleCoordinate
+}
+
+// Add 累加另一份汇总
+func (s *UsageSummary) Add(other UsageSummary) {
+	s.UsageStats.add(other.UsageStats)
+	for role, stats := range other.Roles {
+		s.addRole(role, stats)
+	}
+}
+
+// RoleNames 按名称排序的角色列表
+func (s UsageSummary) RoleNames() []string {
+	names := make([]string, 0, len(s.Roles))
+	for role := range s.Roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addRole 累加一个角色的统计
+func (s *UsageSummary) addRole(role string, stats UsageStats) {
+	if s.Roles == nil {
+		s.Roles = map[string]UsageStats{}
+	}
+	current := s.Roles[role]
+	current.add(stats)
+	s.Roles[role] = current
+}
+
+// clone 深拷贝汇总
+func (s UsageSummary) clone() UsageSummary {
+	copied := UsageSummary{UsageStats: s.UsageStats}
+	for role, stats := range s.Roles {
+		copied.addRole(role, stats)
+	}
+	return copied
+}
+
+// UsageMeter 汇总一个 agent 所有模型客户端的用量，分别累计当前步骤和当前任务
+//
+// 客户端在每次成功的请求后调用 Record，可并发使用。
+type UsageMeter struct {
+	mu   sync.Mutex
+	step UsageSummary
+	task UsageSummary
+}
+
+// NewUsageMeter 创建用量统计
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{}
+}
+
+// Record 记录一次调用的用量及费用，modelName 为实际应答的模型
+func (m *UsageMeter) Record(role string, modelName string, usage Usage, cost float64) {
+	m.add(role, UsageStats{Calls: 1, Usage: usage, Cost: cost, Models: map[string]int{modelName: 1}})
+}
+
+// RecordCacheHit 记录一次由缓存返回、未调用模型的请求
+func (m *UsageMeter) RecordCacheHit(role string) {
+	m.add(role, UsageStats{CacheHits: 1})
+}
+
+// add 把一组统计同时累加到当前步骤和当前任务
+func (m *UsageMeter) add(role string, stats UsageStats) {
+	delta := UsageSummary{UsageStats: stats, Roles: map[string]UsageStats{role: stats}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.step.Add(delta)
+	m.task.Add(delta)
+}
+
+// EndStep 返回当前步骤的用量并开始统计下一步
+func (m *UsageMeter) EndStep() UsageSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	step := m.step
+	m.step = UsageSummary{}
+	return step
+}
+
+// Task 返回当前任务至今的用量
+func (m *UsageMeter) Task() UsageSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.task.clone()
+}
+
+// Reset 清空统计，开始新任务时调用
+func (m *UsageMeter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.step = UsageSummary{}
+	m.task = UsageSummary{}
+}
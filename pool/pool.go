@@ -0,0 +1,238 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/trace"
+)
+
+// State 设备状态
+type State string
+
+// 设备状态
+const (
+	StateIdle    State = "idle"    // 在线且空闲
+	StateBusy    State = "busy"    // 正在执行任务
+	StateOffline State = "offline" // 未连接、未授权或创建 agent 失败
+)
+
+// AgentFactory 为设备创建专属的 PhoneAgent
+type AgentFactory func(deviceID string) (*agent.PhoneAgent, error)
+
+// Options 设备池配置
+type Options struct {
+	Devices        []string      // 只使用这些设备，为空时使用 adb devices 发现的所有设备
+	HealthInterval time.Duration // 设备发现与健康检查的间隔
+	MaxAttempts    int           // 任务因设备离线失败时最多尝试的次数（含首次）
+	TraceDir       string        // 运行记录根目录，为空时不记录
+	TraceMeta      trace.Meta    // 运行记录的模型信息
+}
+
+// DefaultOptions 返回默认配置
+func DefaultOptions() Options {
+	return Options{
+		HealthInterval: 30 * time.Second,
+		MaxAttempts:    2,
+	}
+}
+
+// DeviceStatus 设备状态快照
+type DeviceStatus struct {
+	ID        string    `json:"id"`
+	State     State     `json:"state"`
+	Task      string    `json:"task,omitempty"` // 正在执行的任务
+	Completed int       `json:"completed"`      // 成功完成的任务数
+	Failed    int       `json:"failed"`         // 失败的任务数
+	LastError string    `json:"last_error,omitempty"`
+	LastCheck time.Time `json:"last_check"`
+}
+
+// device 池中的一台设备
+type device struct {
+	status  DeviceStatus
+	agent   *agent.PhoneAgent
+	working bool // 已有调度 goroutine 在使用该设备
+}
+
+// Pool 设备池：发现设备、跟踪健康与忙碌状态，每台设备一个 PhoneAgent
+type Pool struct {
+	opts    Options
+	factory AgentFactory
+
+	mu      sync.Mutex
+	devices map[string]*device
+	order   []string // 设备发现顺序
+}
+
+// New 创建设备池，设备在 Refresh 时发现
+func New(factory AgentFactory, opts Options) *Pool {
+	defaults := DefaultOptions()
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = defaults.HealthInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	return &Pool{
+		opts:    opts,
+		factory: factory,
+		devices: make(map[string]*device),
+	}
+}
+
+// Refresh 发现新设备并检查所有设备的健康状态
+//
+// 在线（adb get-state 为 device）的新设备会创建 agent；执行任务中的设备只更新检查时间，任务结束后再判断。
+func (p *Pool) Refresh(ctx context.Context) error {
+	ids := p.opts.Devices
+	if len(ids) == 0 {
+		found, err := adb.ListDevices(ctx)
+		if err != nil {
+			return err
+		}
+		ids = found
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+		p.check(ctx, id)
+	}
+
+	// 从 adb devices 中消失的设备
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range p.order {
+		dev := p.devices[id]
+		if !seen[id] && dev.status.State == StateIdle {
+			dev.status.State = StateOffline
+			dev.status.LastError = "device disconnected"
+			dev.status.LastCheck = time.Now()
+		}
+	}
+	return nil
+}
+
+// check 检查单台设备，返回是否在线
+func (p *Pool) check(ctx context.Context, id string) bool {
+	state, err := adb.GetDeviceState(ctx, id)
+	if err == nil && state != "device" {
+		err = fmt.Errorf("device state is %s", state)
+	}
+
+	p.mu.Lock()
+	dev, known := p.devices[id]
+	needAgent := !known || dev.agent == nil
+	p.mu.Unlock()
+
+	// 新上线的设备创建 agent，可能较慢，不持有锁
+	var phoneAgent *agent.PhoneAgent
+	if needAgent && err == nil {
+		phoneAgent, err = p.factory(id)
+		if err != nil {
+			err = fmt.Errorf("failed to create agent: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if dev, known = p.devices[id]; !known {
+		dev = &device{status: DeviceStatus{ID: id}}
+		p.devices[id] = dev
+		p.order = append(p.order, id)
+	}
+	if dev.agent == nil {
+		dev.agent = phoneAgent
+	}
+
+	dev.status.LastCheck = time.Now()
+	if dev.status.State == StateBusy {
+		return err == nil
+	}
+	if err != nil {
+		dev.status.State = StateOffline
+		dev.status.LastError = err.Error()
+		return false
+	}
+	dev.status.State = StateIdle
+	dev.status.LastError = ""
+	return true
+}
+
+// Devices 所有设备的状态快照，按发现顺序排列
+func (p *Pool) Devices() []DeviceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]DeviceStatus, 0, len(p.order))
+	for _, id := range p.order {
+		statuses = append(statuses, p.devices[id].status)
+	}
+	return statuses
+}
+
+// acquire 把空闲设备标记为忙碌，设备不可用时返回 nil
+func (p *Pool) acquire(id string, task string) *agent.PhoneAgent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dev, ok := p.devices[id]
+	if !ok || dev.status.State != StateIdle {
+		return nil
+	}
+	dev.status.State = StateBusy
+	dev.status.Task = task
+	return dev.agent
+}
+
+// release 任务结束后恢复设备状态并记录结果
+func (p *Pool) release(id string, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dev := p.devices[id]
+	dev.status.State = StateIdle
+	dev.status.Task = ""
+	if success {
+		dev.status.Completed++
+	} else {
+		dev.status.Failed++
+	}
+}
+
+// claimIdle 返回尚无调度 goroutine 的空闲设备并标记为已占用
+func (p *Pool) claimIdle() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ids []string
+	for _, id := range p.order {
+		dev := p.devices[id]
+		if !dev.working && dev.status.State == StateIdle {
+			dev.working = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// unclaim 调度 goroutine 退出
+func (p *Pool) unclaim(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.devices[id].working = false
+}
+
+// working 正在调度任务的设备数
+func (p *Pool) working() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, dev := range p.devices {
+		if dev.working {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,236 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-phone-agent/agent"
+	"go-phone-agent/model"
+	"go-phone-agent/trace"
+)
+
+// Result 单个任务的执行结果
+type Result struct {
+	Index     int                `json:"index"` // 任务在队列中的序号
+	Task      string             `json:"task"`
+	Device    string             `json:"device,omitempty"` // 最后执行该任务的设备
+	Success   bool               `json:"success"`
+	Result    string             `json:"result"`
+	Steps     int                `json:"steps"`
+	Attempts  int                `json:"attempts"`
+	Duration  time.Duration      `json:"duration"`
+	TraceDir  string             `json:"trace_dir,omitempty"`
+	StartedAt time.Time          `json:"started_at"`
+	Usage     model.UsageSummary `json:"usage"` // 所有尝试的模型用量与费用合计
+}
+
+// Summary 汇总结果
+type Summary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	Duration  time.Duration      // 整个队列的墙钟耗时
+	Usage     model.UsageSummary // 所有任务的模型用量与费用合计
+}
+
+// Summarize 统计任务结果
+func Summarize(results []Result, duration time.Duration) Summary {
+	summary := Summary{Total: len(results), Duration: duration}
+	for _, result := range results {
+		summary.Usage.Add(result.Usage)
+		if result.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// Run 把任务队列分配到池中所有在线设备上并行执行，全部结束后按任务顺序返回结果
+//
+// 每台设备同一时间只执行一个任务；执行期间按 HealthInterval 发现新设备并加入调度。
+// 任务失败且设备随后离线时，任务重新排队交给其他设备，最多尝试 MaxAttempts 次。
+// 所有设备都离线时，剩余任务直接失败。
+func (p *Pool) Run(ctx context.Context, tasks []string) []Result {
+	results := make([]Result, len(tasks))
+	jobs := make(chan int, len(tasks))
+	for i, task := range tasks {
+		results[i] = Result{Index: i, Task: task}
+		jobs <- i
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan int)
+	exited := make(chan struct{}, 1) // 有设备退出调度时唤醒主循环
+	var wg sync.WaitGroup
+	refresh := func() {
+		if err := p.Refresh(runCtx); err != nil {
+			fmt.Printf("Warning: Failed to discover devices: %v\n", err)
+		}
+		for _, id := range p.claimIdle() {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				p.work(runCtx, id, jobs, results, done)
+				p.unclaim(id)
+				select {
+				case exited <- struct{}{}:
+				default:
+				}
+			}(id)
+		}
+	}
+
+	remaining := len(tasks)
+	// fail 排队中的任务直接以 message 结束
+	fail := func(i int, message string) {
+		results[i].Result = message
+		remaining--
+	}
+
+	refresh()
+	ticker := time.NewTicker(p.opts.HealthInterval)
+	defer ticker.Stop()
+
+	for remaining > 0 && ctx.Err() == nil {
+		if p.working() == 0 {
+			// 没有可用设备：重新检查一次，仍然没有则放弃排队中的任务
+			refresh()
+			if p.working() == 0 {
+				for len(jobs) > 0 {
+					fail(<-jobs, "No healthy devices available")
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-done:
+			remaining--
+		case <-exited:
+		case <-ticker.C:
+			refresh()
+		case <-ctx.Done():
+		}
+	}
+
+	// 取消时放弃排队中的任务，等待执行中的任务随 ctx 取消结束
+	for remaining > 0 {
+		select {
+		case <-done:
+			remaining--
+		case i := <-jobs:
+			fail(i, fmt.Sprintf("Task cancelled: %v", ctx.Err()))
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	return results
+}
+
+// work 设备的调度循环：从队列取任务执行，设备不再可用时退出
+func (p *Pool) work(ctx context.Context, id string, jobs chan int, results []Result, done chan<- int) {
+	for {
+		var i int
+		select {
+		case <-ctx.Done():
+			return
+		case i = <-jobs:
+		}
+
+		result := &results[i]
+		phoneAgent := p.acquire(id, result.Task)
+		if phoneAgent == nil {
+			// 设备已离线，任务放回队列由其他设备执行
+			jobs <- i
+			return
+		}
+
+		result.Attempts++
+		result.Device = id
+		result.StartedAt = time.Now()
+		fmt.Printf("[%s] Task %d started: %s\n", id, i+1, result.Task)
+		p.execute(ctx, id, phoneAgent, result)
+		result.Duration = time.Since(result.StartedAt)
+		p.release(id, result.Success)
+
+		if ctx.Err() != nil {
+			done <- i
+			return
+		}
+
+		healthy := p.check(ctx, id)
+		if !healthy && !result.Success && result.Attempts < p.opts.MaxAttempts {
+			fmt.Printf("[%s] Device went offline, requeueing task %d: %s\n", id, i+1, result.Result)
+			jobs <- i
+			return
+		}
+
+		fmt.Printf("[%s] Task %d %s: %s\n", id, i+1, resultStatus(result.Success), result.Result)
+		done <- i
+		if !healthy {
+			return
+		}
+	}
+}
+
+// execute 在设备上运行任务，通过事件总线获取最终是否成功及模型用量
+func (p *Pool) execute(ctx context.Context, id string, phoneAgent *agent.PhoneAgent, result *Result) {
+	success := false
+	unsubscribe := phoneAgent.Events().Subscribe(func(event agent.Event) {
+		if event.Type == agent.EventFinished {
+			success = event.Success
+			if event.Usage != nil {
+				result.Usage.Add(*event.Usage)
+			}
+		}
+	})
+	defer unsubscribe()
+
+	recorder := p.startTrace(id, phoneAgent, result)
+	result.Result = phoneAgent.Run(ctx, result.Task)
+	result.Steps = phoneAgent.GetStepCount()
+	result.Success = success
+
+	if recorder != nil {
+		phoneAgent.SetRecorder(nil)
+		if err := recorder.Close(result.Result); err != nil {
+			fmt.Printf("[%s] Warning: Failed to finish trace: %v\n", id, err)
+		} else if _, err := trace.GenerateReport(recorder.Dir()); err != nil {
+			fmt.Printf("[%s] Warning: Failed to generate report: %v\n", id, err)
+		}
+	}
+}
+
+// startTrace 启用运行记录时为任务创建记录器
+func (p *Pool) startTrace(id string, phoneAgent *agent.PhoneAgent, result *Result) *trace.Recorder {
+	if p.opts.TraceDir == "" {
+		return nil
+	}
+
+	meta := p.opts.TraceMeta
+	meta.Task = result.Task
+	meta.Device = id
+	recorder, err := trace.NewRecorder(p.opts.TraceDir, meta)
+	if err != nil {
+		fmt.Printf("[%s] Warning: Failed to start trace: %v\n", id, err)
+		return nil
+	}
+	phoneAgent.SetRecorder(recorder)
+	result.TraceDir = recorder.Dir()
+	return recorder
+}
+
+// resultStatus 结果的状态文字
+func resultStatus(success bool) string {
+	if success {
+		return "finished"
+	}
+	return "failed"
+}
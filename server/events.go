@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-phone-agent/agent"
+)
+
+// keepAliveInterval 事件流无事件时发送注释行的间隔，防止代理断开空闲连接
+const keepAliveInterval = 15 * time.Second
+
+// streamEvents 以 Server-Sent Events 输出任务事件
+//
+// 先补发任务已产生的事件，再实时推送新事件；任务结束时发送 end 事件（数据为任务状态）并关闭连接。
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+
+	s.mu.Lock()
+	_, ok = s.tasks[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errTaskNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	sent := 0
+	for {
+		s.mu.Lock()
+		task := s.tasks[id]
+		pending := append([]agent.Event{}, task.events[sent:]...)
+		changed := task.changed
+		done := task.done()
+		snapshot := task.snapshot(false)
+		s.mu.Unlock()
+
+		for _, event := range pending {
+			if err := writeEvent(w, string(event.Type), event); err != nil {
+				return
+			}
+		}
+		sent += len(pending)
+
+		if done {
+			writeEvent(w, "end", snapshot)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent 写入一条 SSE 事件
+func writeEvent(w http.ResponseWriter, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
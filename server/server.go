@@ -0,0 +1,448 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/approval"
+	"go-phone-agent/trace"
+)
+
+// Options 服务配置
+type Options struct {
+	Addr      string     // 监听地址，如 127.0.0.1:8080
+	Token     string     // 非空时要求请求携带 Authorization: Bearer <Token>
+	MaxSteps  int        // 每个任务最大步数，0 使用 100
+	TraceDir  string     // 运行记录目录，为空则不记录
+	TraceMeta trace.Meta // 运行记录的模型信息
+
+	Approvals *approval.Queue   // 待审批队列，非 nil 时提供 /api/approvals 接口
+	Canceler  approval.Canceler // 任务取消时结束设备上等待中的审批请求，为空时使用 Approvals
+}
+
+// Server HTTP API 服务，每台设备一个 worker 顺序执行任务
+//
+// 接口：
+//
+//	GET    /api/devices                  设备列表及当前任务
+//	GET    /api/devices/{id}/screenshot  设备当前截图
+//	POST   /api/tasks                    提交任务 {"task": "...", "device_id": "可选"}
+//	GET    /api/tasks                    任务列表
+//	GET    /api/tasks/{id}               任务状态及步骤记录
+//	GET    /api/tasks/{id}/events        任务事件流（Server-Sent Events）
+//	POST   /api/tasks/{id}/cancel        取消任务（DELETE /api/tasks/{id} 同效）
+//	GET    /api/approvals                待审批的确认/接管请求
+//	POST   /api/approvals/{id}           答复请求 {"decision": "approve|deny|resume"}
+type Server struct {
+	opts Options
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	devices []string // 设备注册顺序
+	tasks   map[string]*Task
+	order   []string // 任务提交顺序
+	seq     int
+}
+
+// New 创建服务
+func New(opts Options) *Server {
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = 100
+	}
+	return &Server{
+		opts:    opts,
+		workers: map[string]*worker{},
+		tasks:   map[string]*Task{},
+	}
+}
+
+// AddDevice 注册设备及其专属的 PhoneAgent，需在 Start 之前调用
+func (s *Server) AddDevice(device adb.Device, phoneAgent *agent.PhoneAgent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workers[device.ID()]; !ok {
+		s.devices = append(s.devices, device.ID())
+	}
+	w := &worker{
+		device: device,
+		agent:  phoneAgent,
+		queue:  make(chan *Task, queueSize),
+	}
+	phoneAgent.Events().Subscribe(func(event agent.Event) { s.recordEvent(w, event) })
+	s.workers[device.ID()] = w
+}
+
+// Start 启动所有设备的 worker，ctx 取消后停止并中止正在执行的任务
+func (s *Server) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.workers {
+		go s.runWorker(ctx, w)
+	}
+}
+
+// ListenAndServe 启动 worker 和 HTTP 服务，ctx 取消后优雅退出
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	s.Start(ctx)
+
+	httpServer := &http.Server{Addr: s.opts.Addr, Handler: s.Handler()}
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpServer.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down server: %w", err)
+		}
+		return nil
+	}
+}
+
+// Handler 返回 API 的 http.Handler
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/devices", s.handleDevices)
+	mux.HandleFunc("/api/devices/", s.handleDevice)
+	mux.HandleFunc("/api/tasks", s.handleTasks)
+	mux.HandleFunc("/api/tasks/", s.handleTask)
+	if s.opts.Approvals != nil {
+		mux.HandleFunc("/api/approvals", s.handleApprovals)
+		mux.HandleFunc("/api/approvals/", s.handleApproval)
+	}
+	return s.authorize(mux)
+}
+
+// Submit 提交任务，deviceID 为空时分配给负载最低的设备
+func (s *Server) Submit(taskText string, deviceID string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, err := s.pickWorker(deviceID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	s.seq++
+	task := &Task{
+		ID:        fmt.Sprintf("task-%d", s.seq),
+		Task:      taskText,
+		DeviceID:  w.device.ID(),
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+		changed:   make(chan struct{}),
+	}
+
+	select {
+	case w.queue <- task:
+	default:
+		return Task{}, fmt.Errorf("%w: device %s", errQueueFull, w.device.ID())
+	}
+	s.tasks[task.ID] = task
+	s.order = append(s.order, task.ID)
+	return task.snapshot(false), nil
+}
+
+// Cancel 取消排队中或执行中的任务
+func (s *Server) Cancel(id string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return Task{}, errTaskNotFound
+	}
+	switch {
+	case task.Status == StatusQueued:
+		now := time.Now()
+		task.Status = StatusCancelled
+		task.Result = "Task cancelled before start"
+		task.EndedAt = &now
+		task.broadcast()
+	case task.cancel != nil:
+		task.cancel()
+		// 正在等待人工确认的请求随任务一起结束
+		if canceler := s.approvalCanceler(); canceler != nil {
+			canceler.CancelDevice(task.DeviceID)
+		}
+	}
+	return task.snapshot(false), nil
+}
+
+// approvalCanceler 任务取消时用来结束审批等待的对象，没有则返回 nil
+func (s *Server) approvalCanceler() approval.Canceler {
+	if s.opts.Canceler != nil {
+		return s.opts.Canceler
+	}
+	if s.opts.Approvals != nil {
+		return s.opts.Approvals
+	}
+	return nil
+}
+
+// 服务错误
+var (
+	errTaskNotFound   = errors.New("task not found")
+	errDeviceNotFound = errors.New("device not found")
+	errQueueFull      = errors.New("task queue is full")
+)
+
+// pickWorker 选择执行任务的设备，调用方持有 s.mu
+func (s *Server) pickWorker(deviceID string) (*worker, error) {
+	if deviceID != "" {
+		w, ok := s.workers[deviceID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errDeviceNotFound, deviceID)
+		}
+		return w, nil
+	}
+
+	var best *worker
+	for _, id := range s.devices {
+		w := s.workers[id]
+		if best == nil || w.load() < best.load() {
+			best = w
+		}
+	}
+	if best == nil {
+		return nil, errDeviceNotFound
+	}
+	return best, nil
+}
+
+// authorize 配置了 Token 时校验 Bearer 令牌
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deviceInfo 设备状态
+type deviceInfo struct {
+	ID          string `json:"id"`
+	Busy        bool   `json:"busy"`
+	CurrentTask string `json:"current_task,omitempty"`
+	Queued      int    `json:"queued"`
+}
+
+// handleDevices GET /api/devices
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	s.mu.Lock()
+	devices := make([]deviceInfo, 0, len(s.devices))
+	for _, id := range s.devices {
+		wk := s.workers[id]
+		info := deviceInfo{ID: id, Queued: len(wk.queue)}
+		if wk.current != nil {
+			info.Busy = true
+			info.CurrentTask = wk.current.ID
+		}
+		devices = append(devices, info)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, devices)
+}
+
+// handleDevice GET /api/devices/{id}/screenshot
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/devices/"), "/")
+	if action != "screenshot" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	s.mu.Lock()
+	wk, ok := s.workers[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", errDeviceNotFound, id))
+		return
+	}
+
+	screenshot, err := wk.device.GetScreenshot(r.Context(), 10)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(screenshot.Base64Data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to decode screenshot: %w", err))
+		return
+	}
+
+	mimeType := screenshot.MimeType
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// handleTasks GET/POST /api/tasks
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		tasks := make([]Task, 0, len(s.order))
+		for _, id := range s.order {
+			tasks = append(tasks, s.tasks[id].snapshot(false))
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, tasks)
+
+	case http.MethodPost:
+		var req struct {
+			Task     string `json:"task"`
+			DeviceID string `json:"device_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		req.Task = strings.TrimSpace(req.Task)
+		if req.Task == "" {
+			writeError(w, http.StatusBadRequest, errors.New("task is required"))
+			return
+		}
+
+		task, err := s.Submit(req.Task, req.DeviceID)
+		switch {
+		case errors.Is(err, errDeviceNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, errQueueFull):
+			writeError(w, http.StatusServiceUnavailable, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusAccepted, task)
+		}
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handleTask GET/DELETE /api/tasks/{id}，POST /api/tasks/{id}/cancel，GET /api/tasks/{id}/events
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/tasks/"), "/")
+
+	switch {
+	case action == "events" && r.Method == http.MethodGet:
+		s.streamEvents(w, r, id)
+
+	case action == "" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		task, ok := s.tasks[id]
+		var snapshot Task
+		if ok {
+			snapshot = task.snapshot(true)
+		}
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, errTaskNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+
+	case (action == "" && r.Method == http.MethodDelete) || (action == "cancel" && r.Method == http.MethodPost):
+		task, err := s.Cancel(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, task)
+
+	case action == "" || action == "cancel" || action == "events":
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// handleApprovals GET /api/approvals
+func (s *Server) handleApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Approvals.Pending())
+}
+
+// handleApproval POST /api/approvals/{id}
+func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/approvals/")
+
+	var req struct {
+		Decision approval.Decision `json:"decision"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	err := s.opts.Approvals.Resolve(id, req.Decision)
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"id": id, "decision": string(req.Decision)})
+	}
+}
+
+// Devices 已注册的设备 ID（按注册顺序）
+func (s *Server) Devices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.devices...)
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 输出 JSON 错误响应
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go-phone-agent/agent"
+	"go-phone-agent/model"
+)
+
+// 任务状态
+const (
+	StatusQueued    = "queued"    // 排队等待设备空闲
+	StatusRunning   = "running"   // 正在执行
+	StatusFinished  = "finished"  // 已完成
+	StatusFailed    = "failed"    // 因错误中止（截图失败、模型错误等）或达到最大步数仍未完成
+	StatusCancelled = "cancelled" // 被取消
+)
+
+// Task 提交到服务的任务
+type Task struct {
+	ID        string     `json:"id"`
+	Task      string     `json:"task"`
+	DeviceID  string     `json:"device_id"`
+	Status    string     `json:"status"`
+	Result    string     `json:"result,omitempty"`
+	TraceDir  string     `json:"trace_dir,omitempty"` // 运行记录目录（启用记录时）
+	CreatedAt time.Time  `json:"created_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	StepCount int        `json:"step_count"`
+	Steps     []StepInfo `json:"steps,omitempty"`
+
+	Usage model.UsageSummary `json:"usage"` // 任务至今的模型用量与费用
+
+	cancel  context.CancelFunc // 运行中任务的取消函数
+	events  []agent.Event      // 任务执行过程中的 agent 事件
+	changed chan struct{}      // 有新事件或任务结束时关闭并替换，用于唤醒事件流
+}
+
+// StepInfo 单步执行记录
+type StepInfo struct {
+	Step     int                    `json:"step"`
+	Action   map[string]interface{} `json:"action,omitempty"`
+	Thinking string                 `json:"thinking,omitempty"`
+	Success  bool                   `json:"success"`
+	Finished bool                   `json:"finished"`
+	Message  string                 `json:"message,omitempty"`
+	Usage    model.UsageSummary     `json:"usage"` // 本步模型用量与费用
+	Time     time.Time              `json:"time"`
+}
+
+// done 任务是否已结束
+func (t *Task) done() bool {
+	return t.Status == StatusFinished || t.Status == StatusFailed || t.Status == StatusCancelled
+}
+
+// broadcast 唤醒等待该任务事件的订阅者（调用方持有 Server.mu）
+func (t *Task) broadcast() {
+	if t.changed != nil {
+		close(t.changed)
+	}
+	t.changed = make(chan struct{})
+}
+
+// snapshot 复制任务用于输出（调用方持有 Server.mu）
+func (t *Task) snapshot(withSteps bool) Task {
+	copied := *t
+	copied.cancel = nil
+	copied.events = nil
+	copied.changed = nil
+	if withSteps {
+		copied.Steps = append([]StepInfo{}, t.Steps...)
+	} else {
+		copied.Steps = nil
+	}
+	return copied
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/agent"
+	"go-phone-agent/trace"
+)
+
+// queueSize 每台设备的等待队列长度
+const queueSize = 64
+
+// worker 一台设备及其专属的 PhoneAgent，同一时间只执行一个任务
+type worker struct {
+	device  adb.Device
+	agent   *agent.PhoneAgent
+	queue   chan *Task
+	current *Task // 正在执行的任务，由 Server.mu 保护
+}
+
+// load 设备负载（排队 + 执行中），调用方持有 Server.mu
+func (w *worker) load() int {
+	n := len(w.queue)
+	if w.current != nil {
+		n++
+	}
+	return n
+}
+
+// recordEvent 把设备 agent 的事件追加到当前任务，并唤醒事件流
+func (s *Server) recordEvent(w *worker, event agent.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.current == nil {
+		return
+	}
+	w.current.events = append(w.current.events, event)
+	w.current.broadcast()
+}
+
+// runWorker 依次执行设备队列中的任务，ctx 取消后退出
+func (s *Server) runWorker(ctx context.Context, w *worker) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-w.queue:
+			s.runTask(ctx, w, task)
+		}
+	}
+}
+
+// runTask 执行单个任务并更新状态，排队期间已取消的任务直接跳过
+func (s *Server) runTask(ctx context.Context, w *worker, task *Task) {
+	s.mu.Lock()
+	if task.Status != StatusQueued {
+		s.mu.Unlock()
+		return
+	}
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	now := time.Now()
+	task.Status = StatusRunning
+	task.StartedAt = &now
+	task.cancel = cancel
+	w.current = task
+	s.mu.Unlock()
+
+	fmt.Printf("[%s] Task %s started: %s\n", task.DeviceID, task.ID, task.Task)
+	result, success := s.execute(taskCtx, w, task)
+
+	s.mu.Lock()
+	ended := time.Now()
+	task.EndedAt = &ended
+	task.Result = result
+	task.cancel = nil
+	switch {
+	case taskCtx.Err() != nil:
+		task.Status = StatusCancelled
+	case success:
+		task.Status = StatusFinished
+	default:
+		task.Status = StatusFailed
+	}
+	w.current = nil
+	task.broadcast()
+	s.mu.Unlock()
+
+	fmt.Printf("[%s] Task %s %s: %s\n", task.DeviceID, task.ID, task.Status, result)
+}
+
+// execute 逐步运行 agent，每步结果写入任务的步骤记录
+func (s *Server) execute(ctx context.Context, w *worker, task *Task) (string, bool) {
+	recorder := s.startTrace(w, task)
+
+	w.agent.Reset()
+	// 达到最大步数仍未完成按失败处理，与 agent.Run 和 --tasks 模式一致
+	result, success := "Max steps reached", false
+	for {
+		step := w.agent.Step(ctx, task.Task)
+
+		s.mu.Lock()
+		task.StepCount = w.agent.GetStepCount()
+		task.Steps = append(task.Steps, StepInfo{
+			Step:     task.StepCount,
+			Action:   step.Action,
+			Thinking: step.Thinking,
+			Success:  step.Success,
+			Finished: step.Finished,
+			Message:  step.Message,
+			Usage:    step.Usage,
+			Time:     time.Now(),
+		})
+		task.Usage = w.agent.Usage()
+		s.mu.Unlock()
+
+		if step.Finished {
+			result, success = step.Message, step.Success
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			result, success = fmt.Sprintf("Task cancelled: %v", err), false
+			break
+		}
+		if task.StepCount >= s.opts.MaxSteps {
+			break
+		}
+	}
+
+	if recorder != nil {
+		w.agent.SetRecorder(nil)
+		if err := recorder.Close(result); err != nil {
+			fmt.Printf("[%s] Warning: Failed to finish trace: %v\n", task.DeviceID, err)
+		} else if _, err := trace.GenerateReport(recorder.Dir()); err != nil {
+			fmt.Printf("[%s] Warning: Failed to generate report: %v\n", task.DeviceID, err)
+		}
+	}
+	return result, success
+}
+
+// startTrace 启用运行记录时为任务创建记录器
+func (s *Server) startTrace(w *worker, task *Task) *trace.Recorder {
+	if s.opts.TraceDir == "" {
+		return nil
+	}
+
+	meta := s.opts.TraceMeta
+	meta.Task = task.Task
+	meta.Device = task.DeviceID
+	recorder, err := trace.NewRecorder(s.opts.TraceDir, meta)
+	if err != nil {
+		fmt.Printf("[%s] Warning: Failed to start trace: %v\n", task.DeviceID, err)
+		return nil
+	}
+	w.agent.SetRecorder(recorder)
+
+	s.mu.Lock()
+	task.TraceDir = recorder.Dir()
+	s.mu.Unlock()
+	return recorder
+}
@@ -0,0 +1,234 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-phone-agent/actions"
+	"go-phone-agent/adb"
+)
+
+// Run 从记录目录加载的一次运行
+type Run struct {
+	Dir        string
+	Meta       Meta
+	Steps      []Step
+	ModelCalls []ModelCall
+}
+
+// Load 加载记录目录
+func Load(dir string) (*Run, error) {
+	run := &Run{Dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta: %w", err)
+	}
+	if err := json.Unmarshal(data, &run.Meta); err != nil {
+		return nil, fmt.Errorf("failed to parse meta: %w", err)
+	}
+
+	if err := readJSONL(filepath.Join(dir, IndexFile), func(line []byte) error {
+		var step Step
+		if err := json.Unmarshal(line, &step); err != nil {
+			return err
+		}
+		run.Steps = append(run.Steps, step)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read steps: %w", err)
+	}
+
+	if err := readJSONL(filepath.Join(dir, ModelIndexFile), func(line []byte) error {
+		var call ModelCall
+		if err := json.Unmarshal(line, &call); err != nil {
+			return err
+		}
+		run.ModelCalls = append(run.ModelCalls, call)
+		return nil
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read model calls: %w", err)
+	}
+
+	return run, nil
+}
+
+// readJSONL 逐行读取 JSONL 文件
+func readJSONL(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Screenshot 加载某一步的截图
+func (r *Run) Screenshot(step Step) (*adb.Screenshot, error) {
+	if step.Screenshot == "" {
+		return nil, fmt.Errorf("step %d has no screenshot", step.Step)
+	}
+	data, err := os.ReadFile(filepath.Join(r.Dir, step.Screenshot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshot: %w", err)
+	}
+	screenshot, err := adb.DecodeScreenshot(data)
+	if err != nil {
+		return nil, err
+	}
+	screenshot.IsSensitive = step.Sensitive
+	return screenshot, nil
+}
+
+// FakeDevice 创建按记录顺序返回截图的假设备，用于模型回放
+func (r *Run) FakeDevice() (*adb.FakeDevice, error) {
+	screenshots := make([]*adb.Screenshot, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		if step.Screenshot == "" {
+			continue
+		}
+		screenshot, err := r.Screenshot(step)
+		if err != nil {
+			return nil, err
+		}
+		screenshots = append(screenshots, screenshot)
+	}
+	return adb.NewFakeDevice("replay", screenshots...), nil
+}
+
+// ReplayOptions 动作回放选项
+type ReplayOptions struct {
+	Delay   time.Duration // 每步之间的等待
+	Verbose bool
+}
+
+// ReplayActions 不调用任何模型，按记录顺序在设备上重新执行动作
+//
+// 坐标为 0-1000 归一化值，按设备当前截图的尺寸换算；带 selector 的动作按当前界面层级重新定位。
+func ReplayActions(ctx context.Context, run *Run, device adb.Device, opts ReplayOptions) error {
+	handler := actions.NewActionHandler(device, nil, nil)
+
+	for _, step := range run.Steps {
+		if len(step.Action) == 0 || step.Action["_metadata"] == "finish" {
+			continue
+		}
+		if step.Result != nil && !step.Result.Success {
+			// 原运行中失败的动作不重放
+			continue
+		}
+
+		width, height := step.ScreenWidth, step.ScreenHeight
+		if screenshot, err := device.GetScreenshot(ctx, 10); err == nil {
+			width, height = screenshot.Width, screenshot.Height
+		}
+
+		if opts.Verbose {
+			actionType, _ := step.Action["action"].(string)
+			fmt.Printf("▶ Step %d: %s\n", step.Step, actionType)
+		}
+
+		result, err := handler.Execute(ctx, step.Action, width, height)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", step.Step, err)
+		}
+		if !result.Success {
+			return fmt.Errorf("step %d: %s", step.Step, result.Message)
+		}
+
+		if opts.Delay > 0 {
+			timer := time.NewTimer(opts.Delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return nil
+}
+
+// ReplayTransport 按记录顺序返回模型原始响应的 http.RoundTripper，不访问网络
+type ReplayTransport struct {
+	mu   sync.Mutex
+	run  *Run
+	next int
+}
+
+// NewReplayTransport 创建模型回放传输
+func NewReplayTransport(run *Run) *ReplayTransport {
+	return &ReplayTransport{run: run}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if req.Body != nil {
+		json.NewDecoder(req.Body).Decode(&payload)
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	if t.next >= len(t.run.ModelCalls) {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("replay: no recorded response for model call %d", t.next+1)
+	}
+	call := t.run.ModelCalls[t.next]
+	t.next++
+	t.mu.Unlock()
+
+	// 请求的模型和记录不一致说明 agent 逻辑已偏离原运行
+	if call.Model != "" && payload.Model != "" && call.Model != payload.Model {
+		return nil, fmt.Errorf("replay diverged at model call %d: recorded %s, requested %s", call.Seq, call.Model, payload.Model)
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.run.Dir, call.File))
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(call.Status) + " " + http.StatusText(call.Status),
+		StatusCode:    call.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining 返回尚未使用的记录响应数
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.run.ModelCalls) - t.next
+}
@@ -0,0 +1,264 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-phone-agent/actions"
+)
+
+// ReportFile 运行目录中生成的 HTML 报告文件名
+const ReportFile = "report.html"
+
+// reportView 报告模板数据
+type reportView struct {
+	Meta     Meta
+	Duration string
+	Steps    []reportStep
+}
+
+// reportStep 报告中的单步
+type reportStep struct {
+	Step
+	ActionType string
+	ActionJSON string
+	ImageURI   template.URL // 截图 data URI，为空表示无截图
+	Width      int
+	Height     int
+	Radius     int
+	Points     []reportPoint // 点击类动作的坐标
+	Swipe      *reportSwipe  // 滑动轨迹
+	Target     string        // 无坐标时的目标描述（如控件选择器）
+}
+
+// reportPoint 截图上的点击位置（像素）
+type reportPoint struct {
+	X, Y int
+}
+
+// reportSwipe 截图上的滑动轨迹（像素）
+type reportSwipe struct {
+	X1, Y1, X2, Y2 int
+}
+
+// GenerateReport 为记录目录生成 report.html，返回报告路径
+func GenerateReport(dir string) (string, error) {
+	run, err := Load(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, run); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, ReportFile)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
+
+// WriteReport 输出单文件 HTML 报告，截图以 data URI 内嵌
+func WriteReport(w io.Writer, run *Run) error {
+	view := reportView{Meta: run.Meta}
+	if !run.Meta.EndedAt.IsZero() {
+		view.Duration = formatSeconds(run.Meta.EndedAt.Sub(run.Meta.StartedAt).Seconds())
+	}
+
+	for _, step := range run.Steps {
+		rs, err := buildReportStep(run, step)
+		if err != nil {
+			return err
+		}
+		view.Steps = append(view.Steps, rs)
+	}
+
+	if err := reportTemplate.Execute(w, view); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// screenshotMimeType 按截图文件扩展名返回 MIME 类型（Recorder.RecordStep 写入 .png 或 .jpg）
+func screenshotMimeType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	default:
+		return "image/png"
+	}
+}
+
+// buildReportStep 加载截图并把动作坐标换算到截图像素
+func buildReportStep(run *Run, step Step) (reportStep, error) {
+	rs := reportStep{
+		Step:   step,
+		Width:  step.ScreenWidth,
+		Height: step.ScreenHeight,
+	}
+
+	if step.Screenshot != "" {
+		data, err := os.ReadFile(filepath.Join(run.Dir, step.Screenshot))
+		if err != nil {
+			return rs, fmt.Errorf("failed to read screenshot: %w", err)
+		}
+		rs.ImageURI = template.URL("data:" + screenshotMimeType(step.Screenshot) + ";base64," + base64.StdEncoding.EncodeToString(data))
+	}
+	rs.Radius = max(min(rs.Width, rs.Height)/30, 8)
+
+	if step.Action == nil {
+		return rs, nil
+	}
+	rs.ActionType, _ = step.Action["action"].(string)
+	if step.Action["_metadata"] == "finish" {
+		rs.ActionType = "finish"
+	}
+	if data, err := json.MarshalIndent(step.Action, "", "  "); err == nil {
+		rs.ActionJSON = string(data)
+	}
+
+	// 坐标换算与执行动作时一致（actions.ScreenPoint），保证标注位置就是实际操作位置
+	switch rs.ActionType {
+	case "Tap", "DoubleTap", "LongPress":
+		if element, ok := step.Action["element"]; ok {
+			if x, y, err := actions.ScreenPoint(element, rs.Width, rs.Height); err == nil {
+				rs.Points = append(rs.Points, reportPoint{X: x, Y: y})
+			}
+		} else {
+			rs.Target = selectorLabel(step.Action["selector"])
+		}
+	case "Swipe":
+		x1, y1, err1 := actions.ScreenPoint(step.Action["start"], rs.Width, rs.Height)
+		x2, y2, err2 := actions.ScreenPoint(step.Action["end"], rs.Width, rs.Height)
+		if err1 == nil && err2 == nil {
+			rs.Swipe = &reportSwipe{X1: x1, Y1: y1, X2: x2, Y2: y2}
+		}
+	}
+	return rs, nil
+}
+
+// selectorLabel 控件选择器的简短描述
+func selectorLabel(selector interface{}) string {
+	fields, ok := selector.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	parts := []string{}
+	for _, key := range []string{"text", "resource_id", "content_desc", "index"} {
+		if value, ok := fields[key]; ok && value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	return "selector: " + strings.Join(parts, ", ")
+}
+
+// formatSeconds 格式化耗时
+func formatSeconds(seconds float64) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	if seconds < 1 {
+		return fmt.Sprintf("%.0fms", seconds*1000)
+	}
+	return fmt.Sprintf("%.2fs", seconds)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": formatSeconds,
+	"cost":    func(cost float64) string { return fmt.Sprintf("%.4f", cost) },
+	"time":    func(t interface{ Format(string) string }) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>运行报告 - {{.Meta.Task}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; margin: 0; background: #f4f5f7; color: #222; }
+header { background: #1f2937; color: #fff; padding: 16px 24px; }
+header h1 { margin: 0 0 8px; font-size: 20px; }
+header dl { display: grid; grid-template-columns: max-content 1fr; gap: 4px 12px; margin: 0; font-size: 14px; }
+header dt { color: #9ca3af; }
+header dd { margin: 0; }
+main { padding: 16px 24px; }
+.step { display: flex; gap: 20px; background: #fff; border-radius: 8px; padding: 16px; margin-bottom: 16px; box-shadow: 0 1px 2px rgba(0,0,0,.08); }
+.shot { flex: 0 0 300px; }
+.shot svg { width: 100%; height: auto; border: 1px solid #ddd; border-radius: 4px; background: #000; }
+.info { flex: 1; min-width: 0; font-size: 14px; }
+.info h2 { margin: 0 0 8px; font-size: 16px; }
+.badge { display: inline-block; padding: 1px 8px; border-radius: 10px; font-size: 12px; color: #fff; margin-left: 6px; }
+.ok { background: #16a34a; } .fail { background: #dc2626; } .muted { background: #6b7280; }
+.label { color: #6b7280; margin: 10px 0 2px; }
+pre { white-space: pre-wrap; word-break: break-all; background: #f8f8f8; padding: 8px; border-radius: 4px; margin: 0; font-size: 12px; }
+table.timings { border-collapse: collapse; font-size: 12px; margin-top: 10px; }
+table.timings td, table.timings th { border: 1px solid #e5e7eb; padding: 2px 8px; text-align: right; }
+.error { color: #dc2626; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Meta.Task}}</h1>
+<dl>
+{{if .Meta.Device}}<dt>设备</dt><dd>{{.Meta.Device}}</dd>{{end}}
+{{if .Meta.DecisionModel}}<dt>决策模型</dt><dd>{{.Meta.DecisionModel}}</dd>{{end}}
+{{if .Meta.VisionModel}}<dt>视觉模型</dt><dd>{{.Meta.VisionModel}}</dd>{{end}}
+<dt>开始时间</dt><dd>{{time .Meta.StartedAt}}</dd>
+{{if .Duration}}<dt>总耗时</dt><dd>{{.Duration}}</dd>{{end}}
+<dt>步数</dt><dd>{{len .Steps}}</dd>
+{{if .Meta.Result}}<dt>结果</dt><dd>{{.Meta.Result}}</dd>{{end}}
+{{with .Meta.Usage}}<dt>模型用量</dt><dd>{{.Total}} tokens（输入 {{.PromptTokens}}，输出 {{.CompletionTokens}}）· {{.Calls}} 次调用{{if .CacheHits}} · 缓存命中 {{.CacheHits}} 次{{end}} · 费用 {{cost .Cost}} {{$.Meta.Currency}}</dd>{{end}}
+</dl>
+</header>
+<main>
+{{range .Steps}}{{$step := .Step.Step}}{{$r := .Radius}}
+<section class="step" id="step-{{.Step.Step}}">
+<div class="shot">
+{{if .ImageURI}}
+<svg viewBox="0 0 {{.Width}} {{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<defs><marker id="arrow-{{$step}}" viewBox="0 0 10 10" refX="5" refY="5" markerWidth="4" markerHeight="4" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="#ef4444"/></marker></defs>
+<image href="{{.ImageURI}}" x="0" y="0" width="{{.Width}}" height="{{.Height}}"/>
+{{range .Points}}<circle cx="{{.X}}" cy="{{.Y}}" r="{{$r}}" fill="rgba(239,68,68,.35)" stroke="#ef4444" stroke-width="4"/><circle cx="{{.X}}" cy="{{.Y}}" r="4" fill="#ef4444"/>{{end}}
+{{with .Swipe}}<line x1="{{.X1}}" y1="{{.Y1}}" x2="{{.X2}}" y2="{{.Y2}}" stroke="#ef4444" stroke-width="{{$r}}" stroke-opacity=".8" stroke-linecap="round" marker-end="url(#arrow-{{$step}})"/><circle cx="{{.X1}}" cy="{{.Y1}}" r="{{$r}}" fill="#ef4444"/>{{end}}
+</svg>
+{{else}}<pre>无截图</pre>{{end}}
+</div>
+<div class="info">
+<h2>步骤 {{.Step.Step}} · {{if .ActionType}}{{.ActionType}}{{else}}-{{end}}
+{{with .Result}}{{if .Success}}<span class="badge ok">成功</span>{{else}}<span class="badge fail">失败</span>{{end}}{{else}}<span class="badge muted">未执行</span>{{end}}
+{{if .Sensitive}}<span class="badge muted">敏感页面</span>{{end}}
+</h2>
+{{if .Target}}<div>{{.Target}}</div>{{end}}
+{{if .Note}}<div class="label">校验</div><div>{{.Note}}</div>{{end}}
+{{with .Plan}}
+{{if .Thought}}<div class="label">思考</div><pre>{{.Thought}}</pre>{{end}}
+{{if .Reason}}<div class="label">原因</div><pre>{{.Reason}}</pre>{{end}}
+{{end}}
+{{if .VisionOutput}}<div class="label">视觉模型响应</div><pre>{{.VisionOutput}}</pre>{{end}}
+{{if .ActionJSON}}<div class="label">动作</div><pre>{{.ActionJSON}}</pre>{{end}}
+{{with .Result}}{{if .Message}}<div class="label">结果</div><pre>{{.Message}}</pre>{{end}}{{end}}
+{{if .Error}}<div class="label">错误</div><pre class="error">{{.Error}}</pre>{{end}}
+{{if .ScreenDescription}}<details><summary class="label">屏幕描述</summary><pre>{{.ScreenDescription}}</pre></details>{{end}}
+{{if .DecisionOutput}}<details><summary class="label">决策模型输出</summary><pre>{{.DecisionOutput}}</pre></details>{{end}}
+<table class="timings">
+<tr><th>截图</th><th>屏幕描述</th><th>决策</th><th>视觉</th><th>执行</th><th>总计</th></tr>
+<tr><td>{{seconds .Timings.Screenshot}}</td><td>{{seconds .Timings.Describe}}</td><td>{{seconds .Timings.Decision}}</td><td>{{seconds .Timings.Vision}}</td><td>{{seconds .Timings.Execute}}</td><td>{{seconds .Timings.Total}}</td></tr>
+</table>
+{{with .Usage}}<div class="label">模型用量</div><div>{{.Total}} tokens · {{.Calls}} 次调用{{if .CacheHits}} · 缓存命中 {{.CacheHits}} 次{{end}} · 费用 {{cost .Cost}}</div>
+{{if .Models}}<div class="label">应答模型</div><div>{{range $name, $calls := .Models}}{{$name}} ×{{$calls}} {{end}}</div>{{end}}{{end}}
+</div>
+</section>
+{{end}}
+</main>
+</body>
+</html>
+`
@@ -0,0 +1,64 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"go-phone-agent/adb"
+)
+
+func TestReportScreenshotMimeType(t *testing.T) {
+	tests := []struct {
+		format   imaging.Format
+		mimeType string
+		file     string
+	}{
+		{imaging.PNG, "image/png", "step-001.png"},
+		{imaging.JPEG, "image/jpeg", "step-001.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := imaging.Encode(&buf, imaging.New(108, 240, color.White), tt.format); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			rec, err := NewRecorder(t.TempDir(), Meta{Task: "打开设置"})
+			if err != nil {
+				t.Fatalf("NewRecorder: %v", err)
+			}
+			step := &Step{Step: 1, Image: &adb.Screenshot{
+				Base64Data: base64.StdEncoding.EncodeToString(buf.Bytes()),
+				Width:      108,
+				Height:     240,
+				MimeType:   tt.mimeType,
+			}}
+			if err := rec.RecordStep(step); err != nil {
+				t.Fatalf("RecordStep: %v", err)
+			}
+			if err := rec.Close("完成"); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if step.Screenshot != tt.file {
+				t.Errorf("screenshot file = %s, want %s", step.Screenshot, tt.file)
+			}
+
+			path, err := GenerateReport(rec.Dir())
+			if err != nil {
+				t.Fatalf("GenerateReport: %v", err)
+			}
+			html, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read report: %v", err)
+			}
+			if !strings.Contains(string(html), "data:"+tt.mimeType+";base64,") {
+				t.Errorf("report does not embed the screenshot as %s", tt.mimeType)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package trace
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"go-phone-agent/adb"
+	"go-phone-agent/model"
+)
+
+// 运行目录中的文件名
+const (
+	MetaFile       = "meta.json"    // 运行信息
+	IndexFile      = "index.jsonl"  // 每步一行的 Step 记录
+	ModelIndexFile = "models.jsonl" // 每次模型调用一行的 ModelCall 记录
+	ModelDir       = "models"       // 模型原始响应（SSE）目录
+)
+
+// Meta 一次运行的基本信息
+type Meta struct {
+	Task            string              `json:"task"`
+	Device          string              `json:"device,omitempty"`
+	DecisionModel   string              `json:"decision_model,omitempty"`
+	VisionModel     string              `json:"vision_model,omitempty"`
+	CoordinateModel string              `json:"coordinate_model,omitempty"` // 单独配置坐标识别模型时记录
+	StartedAt       time.Time           `json:"started_at"`
+	EndedAt         time.Time           `json:"ended_at,omitempty"`
+	Result          string              `json:"result,omitempty"`
+	Steps           int                 `json:"steps"`
+	Usage           *model.UsageSummary `json:"usage,omitempty"`    // 各步骤模型用量与费用合计
+	Currency        string              `json:"currency,omitempty"` // 费用币种
+}
+
+// Step 单步的完整记录
+type Step struct {
+	Step              int                    `json:"step"`
+	StartedAt         time.Time              `json:"started_at"`
+	Screenshot        string                 `json:"screenshot,omitempty"` // 截图文件名（相对运行目录）
+	ScreenWidth       int                    `json:"screen_width"`
+	ScreenHeight      int                    `json:"screen_height"`
+	Sensitive         bool                   `json:"sensitive,omitempty"` // 敏感页面（截图为黑色占位图）
+	ScreenDescription string                 `json:"screen_description,omitempty"`
+	DecisionOutput    string                 `json:"decision_output,omitempty"` // 决策模型原始输出
+	Plan              *model.PlanResult      `json:"plan,omitempty"`
+	VisionOutput      string                 `json:"vision_output,omitempty"` // 坐标识别模型原始输出
+	Action            map[string]interface{} `json:"action,omitempty"`        // 实际执行的动作
+	Result            *Result                `json:"result,omitempty"`
+	Note              string                 `json:"note,omitempty"` // 执行校验备注
+	Error             string                 `json:"error,omitempty"`
+	Timings           Timings                `json:"timings"`
+	Usage             *model.UsageSummary    `json:"usage,omitempty"` // 本步模型用量与费用
+
+	Image *adb.Screenshot `json:"-"` // 执行前截图，写入时保存为文件
+}
+
+// Result 动作执行结果
+type Result struct {
+	Success      bool   `json:"success"`
+	ShouldFinish bool   `json:"should_finish"`
+	Message      string `json:"message,omitempty"`
+}
+
+// Timings 各阶段耗时（秒）
+type Timings struct {
+	Screenshot float64 `json:"screenshot"`
+	Describe   float64 `json:"describe"`
+	Decision   float64 `json:"decision"`
+	Vision     float64 `json:"vision"`
+	Execute    float64 `json:"execute"`
+	Total      float64 `json:"total"`
+}
+
+// Recorder 把一次运行的每一步写入独立的目录
+//
+// 目录结构：
+//
+//	<base>/<时间>-<设备>-<任务>[-序号]/
+//	  meta.json         运行信息
+//	  index.jsonl       每步一行 Step
+//	  step-001.png      每步执行前截图
+//	  models.jsonl      每次模型调用一行 ModelCall
+//	  models/0001.sse   模型原始响应
+type Recorder struct {
+	mu         sync.Mutex
+	dir        string
+	index      *os.File
+	modelIndex *os.File
+	meta       Meta
+	modelSeq   int
+}
+
+// NewRecorder 在 baseDir 下为本次运行创建新目录
+//
+// 目录名包含开始时间、设备和任务；多台设备同时执行同一任务时各自使用独立目录，
+// 名称冲突时追加序号，不会复用已有目录。
+func NewRecorder(baseDir string, meta Meta) (*Recorder, error) {
+	if meta.StartedAt.IsZero() {
+		meta.StartedAt = time.Now()
+	}
+	dir, err := createRunDir(baseDir, runName(meta))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(filepath.Join(dir, ModelDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trace directory: %w", err)
+	}
+
+	index, err := os.OpenFile(filepath.Join(dir, IndexFile), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace index: %w", err)
+	}
+	modelIndex, err := os.OpenFile(filepath.Join(dir, ModelIndexFile), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to create model index: %w", err)
+	}
+
+	r := &Recorder{dir: dir, index: index, modelIndex: modelIndex, meta: meta}
+	if err := r.writeMeta(); err != nil {
+		r.Close("")
+		return nil, err
+	}
+	return r, nil
+}
+
+// Dir 返回本次运行的目录
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// RecordStep 保存截图并追加一行步骤记录
+func (r *Recorder) RecordStep(step *Step) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if step.Image != nil && step.Image.Base64Data != "" {
+		data, err := base64.StdEncoding.DecodeString(step.Image.Base64Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode screenshot: %w", err)
+		}
+		ext := ".png"
+		if step.Image.MimeType == "image/jpeg" {
+			ext = ".jpg"
+		}
+		step.Screenshot = fmt.Sprintf("step-%03d%s", step.Step, ext)
+		if err := os.WriteFile(filepath.Join(r.dir, step.Screenshot), data, 0644); err != nil {
+			return fmt.Errorf("failed to write screenshot: %w", err)
+		}
+		step.ScreenWidth = step.Image.Width
+		step.ScreenHeight = step.Image.Height
+		step.Sensitive = step.Image.IsSensitive
+	}
+
+	// 不转义 <、>，方便直接阅读模型输出中的标签
+	encoder := json.NewEncoder(r.index)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(step); err != nil {
+		return fmt.Errorf("failed to write step: %w", err)
+	}
+
+	r.meta.Steps = step.Step
+	if step.Usage != nil {
+		if r.meta.Usage == nil {
+			r.meta.Usage = &model.UsageSummary{}
+		}
+		r.meta.Usage.Add(*step.Usage)
+	}
+	return nil
+}
+
+// Close 写入运行结果并关闭文件
+func (r *Recorder) Close(result string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.meta.EndedAt = time.Now()
+	r.meta.Result = result
+	err := r.writeMeta()
+	if r.index != nil {
+		r.index.Close()
+		r.index = nil
+	}
+	if r.modelIndex != nil {
+		r.modelIndex.Close()
+		r.modelIndex = nil
+	}
+	return err
+}
+
+// maxRunDirAttempts 运行目录重名时最多尝试的序号
+const maxRunDirAttempts = 100
+
+// runName 运行目录名：<时间>-<设备>-<任务>，没有设备时省略
+func runName(meta Meta) string {
+	name := meta.StartedAt.Format("20060102-150405")
+	if meta.Device != "" {
+		name += "-" + slug(meta.Device)
+	}
+	return name + "-" + slug(meta.Task)
+}
+
+// createRunDir 在 baseDir 下创建名为 name 的新目录，已存在时依次尝试 name-2、name-3……
+func createRunDir(baseDir, name string) (string, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	for i := 1; i <= maxRunDirAttempts; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", name, i)
+		}
+		dir := filepath.Join(baseDir, candidate)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to create trace directory: %w", err)
+		}
+	}
+	return "", fmt.Errorf("failed to create trace directory: %s already exists", filepath.Join(baseDir, name))
+}
+
+// writeMeta 写入 meta.json
+func (r *Recorder) writeMeta() error {
+	data, err := json.MarshalIndent(r.meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal meta: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, MetaFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write meta: %w", err)
+	}
+	return nil
+}
+
+var slugPattern = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// slug 把任务描述转换为适合做目录名的短字符串
+func slug(s string) string {
+	s = slugPattern.ReplaceAllString(s, "-")
+	runes := []rune(s)
+	if len(runes) > 32 {
+		runes = runes[:32]
+	}
+	s = string(runes)
+	for len(s) > 0 && s[len(s)-1] == '-' {
+		s = s[:len(s)-1]
+	}
+	for len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return "run"
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package trace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewRecorderUniqueDirs(t *testing.T) {
+	base := t.TempDir()
+	started := time.Date(2026, 10, 16, 9, 30, 0, 0, time.Local)
+
+	// 同一任务在多台设备上同时开始，重复的任务落在同一台设备上
+	metas := []Meta{
+		{Task: "打开设置", Device: "emulator-5554", StartedAt: started},
+		{Task: "打开设置", Device: "192.168.1.20:5555", StartedAt: started},
+		{Task: "打开设置", Device: "emulator-5554", StartedAt: started},
+	}
+	want := []string{
+		"20261016-093000-emulator-5554-打开设置",
+		"20261016-093000-192-168-1-20-5555-打开设置",
+		"20261016-093000-emulator-5554-打开设置-2",
+	}
+	for i, meta := range metas {
+		rec, err := NewRecorder(base, meta)
+		if err != nil {
+			t.Fatalf("NewRecorder: %v", err)
+		}
+		defer rec.Close("")
+		if got := filepath.Base(rec.Dir()); got != want[i] {
+			t.Errorf("dir = %s, want %s", got, want[i])
+		}
+	}
+}
+
+func TestNewRecorderSkipsExistingDir(t *testing.T) {
+	base := t.TempDir()
+	meta := Meta{Task: "打开设置", StartedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.Local)}
+	existing := filepath.Join(base, "20261016-093000-打开设置")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(existing, IndexFile), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := NewRecorder(base, meta)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	defer rec.Close("")
+	if rec.Dir() == existing {
+		t.Fatal("recorder reused an existing run directory")
+	}
+	if data, _ := os.ReadFile(filepath.Join(existing, IndexFile)); string(data) != "{}\n" {
+		t.Errorf("existing index modified: %q", data)
+	}
+}
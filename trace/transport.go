@@ -0,0 +1,131 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ModelCall 一次模型调用的记录
+type ModelCall struct {
+	Seq      int     `json:"seq"`
+	Model    string  `json:"model"`
+	URL      string  `json:"url"`
+	Status   int     `json:"status"`
+	File     string  `json:"file"`     // 原始响应文件（相对运行目录）
+	Duration float64 `json:"duration"` // 从发出请求到读完响应（秒）
+}
+
+// Transport 返回记录模型原始响应的 http.RoundTripper，base 为 nil 时使用 http.DefaultTransport
+func (r *Recorder) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &recordingTransport{recorder: r, base: base}
+}
+
+// recordingTransport 把响应体原样写入 models/ 目录，读完后追加 models.jsonl
+type recordingTransport struct {
+	recorder *Recorder
+	base     http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	modelName := ""
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			var payload struct {
+				Model string `json:"model"`
+			}
+			if json.NewDecoder(body).Decode(&payload) == nil {
+				modelName = payload.Model
+			}
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	call, file, err := t.recorder.newModelCall(modelName, req.URL.String(), resp.StatusCode)
+	if err != nil {
+		// 记录失败不影响正常请求
+		return resp, nil
+	}
+
+	resp.Body = &recordingBody{
+		body: resp.Body,
+		file: file,
+		onClose: func() {
+			call.Duration = time.Since(start).Seconds()
+			t.recorder.appendModelCall(call)
+		},
+	}
+	return resp, nil
+}
+
+// newModelCall 分配序号并创建响应文件
+func (r *Recorder) newModelCall(modelName, url string, status int) (*ModelCall, *os.File, error) {
+	r.mu.Lock()
+	r.modelSeq++
+	seq := r.modelSeq
+	r.mu.Unlock()
+
+	name := filepath.ToSlash(filepath.Join(ModelDir, fmt.Sprintf("%04d.sse", seq)))
+	file, err := os.Create(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ModelCall{Seq: seq, Model: modelName, URL: url, Status: status, File: name}, file, nil
+}
+
+// appendModelCall 追加一行模型调用记录
+func (r *Recorder) appendModelCall(call *ModelCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.modelIndex == nil {
+		return
+	}
+	line, err := json.Marshal(call)
+	if err != nil {
+		return
+	}
+	r.modelIndex.Write(append(line, '\n'))
+}
+
+// recordingBody 读取响应体的同时写入文件
+type recordingBody struct {
+	body    io.ReadCloser
+	file    *os.File
+	onClose func()
+	closed  bool
+}
+
+// Read 实现 io.Reader
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.file.Write(p[:n])
+	}
+	return n, err
+}
+
+// Close 关闭响应体和文件，并写入调用记录
+func (b *recordingBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	err := b.body.Close()
+	b.file.Close()
+	b.onClose()
+	return err
+}